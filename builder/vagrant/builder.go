@@ -59,6 +59,16 @@ type Config struct {
 	// source_path, Packer will skip the Vagrant initialize and add steps, and
 	// simply launch the box directly using the global id.
 	GlobalID string `mapstructure:"global_id" required:"true"`
+	// The name or UUID of an existing VirtualBox VM that was created outside
+	// of Vagrant. If you choose to use base_vm instead of source_path or
+	// global_id, Packer will skip the Vagrant initialize, add and up steps,
+	// and package the VM directly using `vagrant package --base`. Packer
+	// neither starts nor tears down this VM, so it should already be running
+	// if you want to provision it. Since Vagrant knows nothing about how to
+	// reach the VM, you must set ssh_host and ssh_username (and any other
+	// credentials) to provision it, or set communicator to "none" to package
+	// it as is. You may only set one of source_path, global_id or base_vm.
+	BaseVM string `mapstructure:"base_vm" required:"true"`
 	// The checksum for the .box file. The type of the checksum is specified
 	// within the checksum field as a prefix, ex: "md5:{$checksum}". The type
 	// of the checksum can also be omitted and Packer will try to infer it
@@ -109,6 +119,27 @@ type Config struct {
 	// Path to the folder to be synced to the guest. The path can be absolute
 	// or relative to the directory Packer is being run from.
 	SyncedFolder string `mapstructure:"synced_folder"`
+	// A list of folders to sync to the source machine, for when mounting a
+	// single folder at /vagrant with synced_folder isn't enough. Each entry
+	// is rendered as a `source.vm.synced_folder` line in the generated
+	// Vagrantfile. If any of them has type "rsync", Packer runs
+	// `vagrant rsync` before provisioning so the guest has the latest
+	// contents. All of these folders are disabled in the Vagrantfile embedded
+	// in the packaged box, by their `id` option or else their guest path, so
+	// users of the box don't inherit the build mounts. See [Synced Folder Configuration](#synced-folder-configuration)
+	// for the available options.
+	SyncedFolders []SyncedFolderConfig `mapstructure:"synced_folders" required:"false"`
+	// Cloud-init user data to pass to the source machine through Vagrant's
+	// `config.vm.cloud_init` setting. This can either be the user data
+	// itself, starting with its header like `#cloud-config` or `#!`, in
+	// which case it is written to a `user-data` file in the output
+	// directory, or the path to an existing file; anything else is an error.
+	// Content starting with `#!` is sent as a shell script, anything else as
+	// cloud-config. This requires Vagrant 2.3 or later; Packer enables the
+	// `cloud_init` experimental feature for you.
+	// It can't be used together with global_id or base_vm since Packer
+	// doesn't create the Vagrantfile in those cases.
+	CloudInitUserData string `mapstructure:"cloud_init_user_data" required:"false"`
 	// Don't call "vagrant box add" to add the box to your local environment; this
 	// is necessary if you want to launch a box that is already added to your
 	// vagrant environment.
@@ -137,6 +168,28 @@ type Config struct {
 	// --insecure flag in
 	// vagrant add; defaults to unset.
 	AddInsecure bool `mapstructure:"add_insecure" required:"false"`
+	// If true, Packer runs the provisioners defined in your Vagrantfile with
+	// `vagrant provision` as a separate step, with their output streamed to
+	// the UI, before any of the Packer provisioners run. `vagrant up` is then
+	// called with `--no-provision` so they don't run twice. This is useful
+	// with a custom template containing `config.vm.provision` blocks.
+	VagrantProvision bool `mapstructure:"vagrant_provision" required:"false"`
+	// The names of the Vagrant provisioners to run when vagrant_provision is
+	// set. Equivalent to the `--provision-with` option of `vagrant provision`;
+	// by default all of them run.
+	VagrantProvisionWith []string `mapstructure:"vagrant_provision_with" required:"false"`
+	// If true, Packer leaves the source machine running when the build
+	// finishes, instead of tearing it down, and records its id in output_dir.
+	// When you build again with the same output_dir and that machine is still
+	// running, Packer skips the Vagrant initialize, add and up steps and goes
+	// straight to provisioning it, which makes iterating on provisioners much
+	// faster. A stopped machine is brought back up first. When the state of
+	// the recorded machine can't be read, the build fails rather than leave
+	// it behind; remove packer_machine_id from output_dir to start over. This
+	// can't be used together with global_id or base_vm. Since `vagrant
+	// package` stops the machine, you will usually want to set skip_package
+	// as well.
+	KeepMachine bool `mapstructure:"keep_machine" required:"false"`
 	// if true, Packer will not call vagrant package to
 	// package your base box into its own standalone .box file.
 	SkipPackage       bool   `mapstructure:"skip_package" required:"false"`
@@ -178,7 +231,7 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, []string, error) {
 		b.config.Comm.SSHTimeout = 10 * time.Minute
 	}
 
-	if b.config.Comm.Type != "ssh" {
+	if b.config.Comm.Type != "ssh" && !(b.config.BaseVM != "" && b.config.Comm.Type == "none") {
 		errs = packersdk.MultiErrorAppend(errs,
 			fmt.Errorf(`The Vagrant builder currently only supports the ssh communicator"`))
 	}
@@ -187,9 +240,29 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, []string, error) {
 		b.config.BoxName = fmt.Sprintf("packer_%s", b.config.PackerBuildName)
 	}
 
-	if b.config.SourceBox == "" {
+	if b.config.BaseVM != "" {
+		if b.config.SourceBox != "" || b.config.GlobalID != "" {
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("You may only set one of base_vm, global_id or source_path"))
+		}
+		// vagrant package --base is only implemented by the VirtualBox provider
+		if b.config.Provider == "" {
+			b.config.Provider = "virtualbox"
+		} else if b.config.Provider != "virtualbox" {
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("base_vm is only supported with the virtualbox provider"))
+		}
+		if b.config.Comm.Type == "ssh" {
+			if b.config.Comm.SSHHost == "" || b.config.Comm.SSHUsername == "" {
+				errs = packersdk.MultiErrorAppend(errs,
+					fmt.Errorf("ssh_host and ssh_username must be set when using base_vm, "+
+						"or set communicator to \"none\" to skip provisioning"))
+			}
+			if b.config.Comm.SSHPort == 0 {
+				b.config.Comm.SSHPort = 22
+			}
+		}
+	} else if b.config.SourceBox == "" {
 		if b.config.GlobalID == "" {
-			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("source_path is required unless you have set global_id"))
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("source_path is required unless you have set global_id or base_vm"))
 		}
 	} else {
 		if b.config.GlobalID != "" {
@@ -269,6 +342,47 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, []string, error) {
 		}
 	}
 
+	if b.config.KeepMachine {
+		if b.config.GlobalID != "" || b.config.BaseVM != "" {
+			errs = packersdk.MultiErrorAppend(errs,
+				fmt.Errorf("keep_machine can't be used with global_id or base_vm"))
+		}
+		if !b.config.SkipPackage {
+			warnings = append(warnings,
+				"keep_machine is set without skip_package; vagrant package will stop "+
+					"the machine, so the next build will have to bring it up again.")
+		}
+	}
+
+	if b.config.BaseVM != "" && b.config.VagrantProvision {
+		errs = packersdk.MultiErrorAppend(errs,
+			fmt.Errorf("vagrant_provision can't be used with base_vm"))
+	}
+	if len(b.config.VagrantProvisionWith) > 0 && !b.config.VagrantProvision {
+		errs = packersdk.MultiErrorAppend(errs,
+			fmt.Errorf("vagrant_provision_with requires vagrant_provision to be set"))
+	}
+
+	for i := range b.config.SyncedFolders {
+		errs = packersdk.MultiErrorAppend(errs, b.config.SyncedFolders[i].Prepare()...)
+	}
+	if len(b.config.SyncedFolders) > 0 && (b.config.GlobalID != "" || b.config.BaseVM != "") {
+		errs = packersdk.MultiErrorAppend(errs,
+			fmt.Errorf("synced_folders can't be used with global_id or base_vm"))
+	}
+
+	if b.config.CloudInitUserData != "" && (b.config.GlobalID != "" || b.config.BaseVM != "") {
+		errs = packersdk.MultiErrorAppend(errs,
+			fmt.Errorf("cloud_init_user_data can't be used with global_id or base_vm"))
+	}
+	if b.config.CloudInitUserData != "" && !isInlineUserData(b.config.CloudInitUserData) {
+		if info, err := os.Stat(b.config.CloudInitUserData); err != nil || info.IsDir() {
+			errs = packersdk.MultiErrorAppend(errs,
+				fmt.Errorf("cloud_init_user_data must be the path to a file, or user data starting with "+
+					"its header like #cloud-config or #!: %s is not a file", b.config.CloudInitUserData))
+		}
+	}
+
 	if errs != nil && len(errs.Errors) > 0 {
 		return nil, warnings, errs
 	}
@@ -276,6 +390,52 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, []string, error) {
 	return nil, warnings, nil
 }
 
+// experimentalFeatures adds features to a VAGRANT_EXPERIMENTAL value without
+// dropping the ones the user has already enabled.
+func experimentalFeatures(current string, features ...string) string {
+	enabled := []string{}
+	for _, f := range strings.Split(current, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			enabled = append(enabled, f)
+		}
+	}
+	for _, f := range features {
+		found := false
+		for _, e := range enabled {
+			// "1" turns on every experimental feature
+			if e == f || e == "1" {
+				found = true
+				break
+			}
+		}
+		if !found {
+			enabled = append(enabled, f)
+		}
+	}
+	return strings.Join(enabled, ",")
+}
+
+// reusableMachine returns the id and state of the machine recorded by a
+// previous keep_machine build in outputDir, if Vagrant still knows about it.
+// The state of the machine must be known: starting over could leave it
+// behind, so the build fails when vagrant status does.
+func reusableMachine(driver VagrantDriver, outputDir string) (string, string, error) {
+	id := readMachineID(outputDir)
+	if id == "" {
+		return "", "", nil
+	}
+	machineState, err := driver.Status(id)
+	if err != nil {
+		return "", "", fmt.Errorf("Error reading the state of the Vagrant machine %s, recorded in %s by a "+
+			"previous build: %s\nRemove that file to start over with a new machine.",
+			id, filepath.Join(outputDir, machineIDFile), err)
+	}
+	if machineState == "not_created" {
+		return "", "", nil
+	}
+	return id, machineState, nil
+}
+
 // Run executes a Packer build and returns a packersdk.Artifact representing
 // a VirtualBox appliance.
 func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook) (packersdk.Artifact, error) {
@@ -284,7 +444,12 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 	if err != nil {
 		return nil, err
 	}
-	driver, err := NewDriver(VagrantCWD)
+	var env []string
+	if b.config.CloudInitUserData != "" {
+		env = append(env, "VAGRANT_EXPERIMENTAL="+
+			experimentalFeatures(os.Getenv("VAGRANT_EXPERIMENTAL"), "cloud_init"))
+	}
+	driver, err := NewDriver(VagrantCWD, env)
 	if err != nil {
 		return nil, fmt.Errorf("Failed creating VirtualBox driver: %s", err)
 	}
@@ -309,53 +474,125 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 			Url:         []string{b.config.SourceBox},
 		})
 	}
-	steps = append(steps,
-		&commonsteps.StepOutputDir{
+	// With keep_machine, a machine left behind by a previous build in the
+	// same output directory is picked up again instead of starting over.
+	machineID, machineState := "", ""
+	if b.config.KeepMachine {
+		if machineID, machineState, err = reusableMachine(driver, b.config.OutputDir); err != nil {
+			return nil, err
+		}
+	}
+
+	globalID := b.config.GlobalID
+	switch {
+	case machineID != "":
+		ui.Say(fmt.Sprintf("Reusing Vagrant machine %s from a previous build (%s)...", machineID, machineState))
+		globalID = machineID
+		// vagrant package refuses to overwrite the box of the previous build
+		if err := os.Remove(filepath.Join(VagrantCWD, "package.box")); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		if machineState == "running" {
+			state.Put("instance_id", machineID)
+		} else {
+			steps = append(steps, &StepUp{
+				TeardownMethod: b.config.TeardownMethod,
+				Provider:       b.config.Provider,
+				GlobalID:       machineID,
+				KeepMachine:    true,
+				NoProvision:    b.config.VagrantProvision,
+			})
+		}
+		steps = append(steps, &StepSSHConfig{
+			machineID,
+		})
+	case b.config.BaseVM != "":
+		steps = append(steps,
+			&commonsteps.StepOutputDir{
+				Force: b.config.PackerForce,
+				Path:  b.config.OutputDir,
+			})
+		// The base VM isn't managed by Vagrant, so there is nothing to
+		// initialize, add or bring up, and we connect to it directly using
+		// the SSH settings from the template.
+		ui.Say(fmt.Sprintf("Using base_vm %q; skipping Vagrant init, add and up...", b.config.BaseVM))
+		state.Put("instance_id", b.config.BaseVM)
+	default:
+		outputDir := &commonsteps.StepOutputDir{
 			Force: b.config.PackerForce,
 			Path:  b.config.OutputDir,
-		},
-		&StepCreateVagrantfile{
-			Template:     b.config.Template,
-			SyncedFolder: b.config.SyncedFolder,
-			SourceBox:    b.config.SourceBox,
-			BoxName:      b.config.BoxName,
-			OutputDir:    b.config.OutputDir,
-			GlobalID:     b.config.GlobalID,
-			InsertKey:    b.config.InsertKey,
-		},
-		&StepAddBox{
-			BoxVersion:   b.config.BoxVersion,
-			CACert:       b.config.AddCACert,
-			CAPath:       b.config.AddCAPath,
-			DownloadCert: b.config.AddCert,
-			Clean:        b.config.AddClean,
-			Force:        b.config.AddForce,
-			Insecure:     b.config.AddInsecure,
-			Provider:     b.config.Provider,
-			SourceBox:    b.config.SourceBox,
-			BoxName:      b.config.BoxName,
-			GlobalID:     b.config.GlobalID,
-			SkipAdd:      b.config.SkipAdd,
-		},
-		&StepUp{
-			TeardownMethod: b.config.TeardownMethod,
-			Provider:       b.config.Provider,
-			GlobalID:       b.config.GlobalID,
-		},
-		&StepSSHConfig{
-			b.config.GlobalID,
-		},
+		}
+		if b.config.KeepMachine {
+			// Removing the directory would orphan the machine
+			steps = append(steps, &StepKeepOutputDir{outputDir})
+		} else {
+			steps = append(steps, outputDir)
+		}
+		steps = append(steps,
+			&StepCreateVagrantfile{
+				Template:          b.config.Template,
+				SyncedFolder:      b.config.SyncedFolder,
+				SourceBox:         b.config.SourceBox,
+				BoxName:           b.config.BoxName,
+				OutputDir:         b.config.OutputDir,
+				GlobalID:          b.config.GlobalID,
+				InsertKey:         b.config.InsertKey,
+				CloudInitUserData: b.config.CloudInitUserData,
+				SyncedFolders:     b.config.SyncedFolders,
+			},
+			&StepAddBox{
+				BoxVersion:   b.config.BoxVersion,
+				CACert:       b.config.AddCACert,
+				CAPath:       b.config.AddCAPath,
+				DownloadCert: b.config.AddCert,
+				Clean:        b.config.AddClean,
+				Force:        b.config.AddForce,
+				Insecure:     b.config.AddInsecure,
+				Provider:     b.config.Provider,
+				SourceBox:    b.config.SourceBox,
+				BoxName:      b.config.BoxName,
+				GlobalID:     b.config.GlobalID,
+				SkipAdd:      b.config.SkipAdd,
+			},
+			&StepUp{
+				TeardownMethod: b.config.TeardownMethod,
+				Provider:       b.config.Provider,
+				GlobalID:       b.config.GlobalID,
+				KeepMachine:    b.config.KeepMachine,
+				OutputDir:      b.config.OutputDir,
+				NoProvision:    b.config.VagrantProvision,
+			},
+			&StepSSHConfig{
+				b.config.GlobalID,
+			})
+	}
+	steps = append(steps,
 		&communicator.StepConnect{
 			Config:    &b.config.Comm,
 			Host:      CommHost(),
 			SSHConfig: b.config.Comm.SSHConfigFunc(),
-		},
+		})
+	if usesRsync(b.config.SyncedFolders) {
+		steps = append(steps, &StepRsync{
+			GlobalID: globalID,
+		})
+	}
+	if b.config.VagrantProvision {
+		steps = append(steps, &StepVagrantProvision{
+			GlobalID:      globalID,
+			ProvisionWith: b.config.VagrantProvisionWith,
+		})
+	}
+	steps = append(steps,
 		new(commonsteps.StepProvision),
 		&StepPackage{
-			SkipPackage: b.config.SkipPackage,
-			Include:     b.config.PackageInclude,
-			Vagrantfile: b.config.OutputVagrantfile,
-			GlobalID:    b.config.GlobalID,
+			SkipPackage:   b.config.SkipPackage,
+			Include:       b.config.PackageInclude,
+			Vagrantfile:   b.config.OutputVagrantfile,
+			GlobalID:      globalID,
+			BaseVM:        b.config.BaseVM,
+			SyncedFolders: b.config.SyncedFolders,
+			OutputDir:     b.config.OutputDir,
 		})
 
 	// Run the steps.
@@ -4,6 +4,10 @@
 package vagrant
 
 import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
@@ -122,6 +126,94 @@ func TestBuilder_Prepare_ValidateSource(t *testing.T) {
 			errExpected: false,
 			reason:      "Should pass because path is not local",
 		},
+		{
+			config: map[string]interface{}{
+				"communicator": "ssh",
+				"base_vm":      "golden",
+				"ssh_host":     "10.0.0.5",
+				"ssh_username": "vagrant",
+			},
+			errExpected: false,
+			reason:      "Base VM with an SSH endpoint is valid",
+		},
+		{
+			config: map[string]interface{}{
+				"communicator": "none",
+				"base_vm":      "golden",
+			},
+			errExpected: false,
+			reason:      "Base VM may be packaged without provisioning",
+		},
+		{
+			config: map[string]interface{}{
+				"communicator": "ssh",
+				"base_vm":      "golden",
+			},
+			errExpected: true,
+			reason:      "Base VM needs an SSH endpoint to provision",
+		},
+		{
+			config: map[string]interface{}{
+				"communicator": "none",
+				"base_vm":      "golden",
+				"global_id":    "a3559ec",
+			},
+			errExpected: true,
+			reason:      "Both base VM and global id are set: we should error.",
+		},
+		{
+			config: map[string]interface{}{
+				"communicator": "none",
+				"base_vm":      "golden",
+				"provider":     "libvirt",
+			},
+			errExpected: true,
+			reason:      "Base VM can only be packaged by the virtualbox provider",
+		},
+		{
+			config: map[string]interface{}{
+				"communicator": "none",
+				"global_id":    "a3559ec",
+			},
+			errExpected: true,
+			reason:      "The none communicator is only valid with base_vm",
+		},
+		{
+			config: map[string]interface{}{
+				"communicator": "ssh",
+				"source_path":  "hashicorp/precise64",
+				"keep_machine": true,
+			},
+			errExpected: false,
+			reason:      "Keeping a machine built from a source path is valid",
+		},
+		{
+			config: map[string]interface{}{
+				"communicator": "ssh",
+				"global_id":    "a3559ec",
+				"keep_machine": true,
+			},
+			errExpected: true,
+			reason:      "A global id is already kept by the user",
+		},
+		{
+			config: map[string]interface{}{
+				"communicator":         "ssh",
+				"source_path":          "hashicorp/precise64",
+				"cloud_init_user_data": "#cloud-config\npackages:\n  - git\n",
+			},
+			errExpected: false,
+			reason:      "Inline user data starts with its header",
+		},
+		{
+			config: map[string]interface{}{
+				"communicator":         "ssh",
+				"source_path":          "hashicorp/precise64",
+				"cloud_init_user_data": "cloud-init/user-data.yml",
+			},
+			errExpected: true,
+			reason:      "A mistyped path to the user data isn't taken for the user data itself",
+		},
 	}
 
 	for _, tc := range cases {
@@ -131,3 +223,49 @@ func TestBuilder_Prepare_ValidateSource(t *testing.T) {
 		}
 	}
 }
+
+func TestExperimentalFeatures(t *testing.T) {
+	cases := []struct {
+		current  string
+		expected string
+	}{
+		{"", "cloud_init"},
+		{"disks", "disks,cloud_init"},
+		{"cloud_init, disks", "cloud_init,disks"},
+		{"1", "1"},
+	}
+	for _, tc := range cases {
+		if got := experimentalFeatures(tc.current, "cloud_init"); got != tc.expected {
+			t.Fatalf("experimentalFeatures(%q): expected %q, got %q", tc.current, tc.expected, got)
+		}
+	}
+}
+
+func TestReusableMachine(t *testing.T) {
+	outputDir := t.TempDir()
+	driver := &MockVagrantDriver{ReturnStatus: "running"}
+
+	if id, _, err := reusableMachine(driver, outputDir); id != "" || err != nil {
+		t.Fatalf("no machine should be reused without a recorded id, got %q (%v)", id, err)
+	}
+	if driver.StatusCalled {
+		t.Fatalf("status should not be checked without a recorded id")
+	}
+
+	if err := os.WriteFile(filepath.Join(outputDir, machineIDFile), []byte("a3559ec\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if id, state, err := reusableMachine(driver, outputDir); id != "a3559ec" || state != "running" || err != nil {
+		t.Fatalf("expected running machine a3559ec, got %q (%s, %v)", id, state, err)
+	}
+
+	driver.ReturnStatus = "not_created"
+	if id, _, err := reusableMachine(driver, outputDir); id != "" || err != nil {
+		t.Fatalf("a machine that doesn't exist anymore can't be reused, got %q (%v)", id, err)
+	}
+
+	driver.ReturnError = errors.New("status command returned errors")
+	if _, _, err := reusableMachine(driver, outputDir); err == nil || !strings.Contains(err.Error(), machineIDFile) {
+		t.Fatalf("an unknown machine state should fail the build, got %v", err)
+	}
+}
@@ -22,6 +22,13 @@ type VagrantDriver interface {
 	// Calls "vagrant up"
 	Up([]string) (string, string, error)
 
+	// Calls "vagrant provision", passing each line of its output to the
+	// given func
+	Provision([]string, func(string)) error
+
+	// Calls "vagrant rsync"
+	Rsync(string) error
+
 	// Calls "vagrant halt"
 	Halt(string) error
 
@@ -30,6 +37,10 @@ type VagrantDriver interface {
 
 	SSHConfig(string) (*VagrantSSHConfig, error)
 
+	// Calls "vagrant status" and returns the state of the machine, such as
+	// "running", "poweroff" or "not_created"
+	Status(string) (string, error)
+
 	// Calls "vagrant destroy"
 	Destroy(string) error
 
@@ -45,7 +56,7 @@ type VagrantDriver interface {
 	Version() (string, error)
 }
 
-func NewDriver(outputDir string) (VagrantDriver, error) {
+func NewDriver(outputDir string, env []string) (VagrantDriver, error) {
 	// Hardcode path for now while I'm developing. Obviously this path needs
 	// to be discovered based on OS.
 	vagrantBinary := "vagrant"
@@ -60,6 +71,7 @@ func NewDriver(outputDir string) (VagrantDriver, error) {
 	driver := &Vagrant_2_2_Driver{
 		vagrantBinary: vagrantBinary,
 		VagrantCWD:    outputDir,
+		Env:           env,
 	}
 
 	if err := driver.Verify(); err != nil {
@@ -21,6 +21,9 @@ const VAGRANT_MIN_VERSION = ">= 2.0.2"
 type Vagrant_2_2_Driver struct {
 	vagrantBinary string
 	VagrantCWD    string
+	// Extra environment variables, in "key=value" form, passed to every
+	// Vagrant command.
+	Env []string
 }
 
 // Calls "vagrant init"
@@ -42,6 +45,22 @@ func (d *Vagrant_2_2_Driver) Up(args []string) (string, string, error) {
 	return stdout, stderr, err
 }
 
+// Calls "vagrant provision"
+func (d *Vagrant_2_2_Driver) Provision(args []string, output func(string)) error {
+	_, _, err := d.vagrantCmdWithOutput(output, append([]string{"provision"}, args...)...)
+	return err
+}
+
+// Calls "vagrant rsync"
+func (d *Vagrant_2_2_Driver) Rsync(id string) error {
+	args := []string{"rsync"}
+	if id != "" {
+		args = append(args, id)
+	}
+	_, _, err := d.vagrantCmd(args...)
+	return err
+}
+
 // Calls "vagrant halt"
 func (d *Vagrant_2_2_Driver) Halt(id string) error {
 	args := []string{"halt"}
@@ -184,6 +203,42 @@ func (d *Vagrant_2_2_Driver) SSHConfig(id string) (*VagrantSSHConfig, error) {
 	return sshConf, err
 }
 
+// Calls "vagrant status"
+func (d *Vagrant_2_2_Driver) Status(id string) (string, error) {
+	args := []string{"status", "--machine-readable"}
+	if id != "" {
+		args = append(args, id)
+	}
+	stdout, stderr, err := d.vagrantCmd(args...)
+	if err != nil {
+		if stderr != "" {
+			err = fmt.Errorf("status command returned errors: %s", stderr)
+		}
+		return "", err
+	}
+	state := parseMachineState(stdout)
+	if state == "" {
+		return "", fmt.Errorf("error: machine state was not found in vagrant status output.")
+	}
+	return state, nil
+}
+
+// parseMachineState finds the state in the machine readable output of
+// vagrant status. Example stdout:
+//
+//	1700000000,source,metadata,provider,virtualbox
+//	1700000000,source,provider-name,virtualbox
+//	1700000000,source,state,running
+func parseMachineState(stdout string) string {
+	for _, line := range strings.Split(stdout, "\n") {
+		fields := strings.Split(strings.TrimSpace(line), ",")
+		if len(fields) >= 4 && fields[2] == "state" {
+			return fields[3]
+		}
+	}
+	return ""
+}
+
 // Version reads the version of VirtualBox that is installed.
 func (d *Vagrant_2_2_Driver) Version() (string, error) {
 	stdoutString, _, err := d.vagrantCmd([]string{"--version"}...)
@@ -230,9 +285,16 @@ func ScanLinesInclCR(data []byte, atEOF bool) (advance int, token []byte, err er
 }
 
 func (d *Vagrant_2_2_Driver) vagrantCmd(args ...string) (string, string, error) {
+	return d.vagrantCmdWithOutput(nil, args...)
+}
+
+// vagrantCmdWithOutput runs a Vagrant command like vagrantCmd, additionally
+// passing every line of standard output to the output func, if it is set.
+func (d *Vagrant_2_2_Driver) vagrantCmdWithOutput(output func(string), args ...string) (string, string, error) {
 	log.Printf("Calling Vagrant CLI: %#v", args)
 	cmd := exec.Command(d.vagrantBinary, args...)
 	cmd.Env = append(os.Environ(), fmt.Sprintf("VAGRANT_CWD=%s", d.VagrantCWD))
+	cmd.Env = append(cmd.Env, d.Env...)
 
 	stderr, err := cmd.StderrPipe()
 	if err != nil {
@@ -269,6 +331,9 @@ func (d *Vagrant_2_2_Driver) vagrantCmd(args ...string) (string, string, error)
 		line := scanOut.Text()
 		log.Printf("[vagrant driver] stdout: %s", line)
 		stdoutString += line + "\n"
+		if output != nil {
+			output(line)
+		}
 	}
 	err = cmd.Wait()
 
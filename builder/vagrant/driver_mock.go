@@ -8,9 +8,12 @@ type MockVagrantDriver struct {
 	InitCalled      bool
 	AddCalled       bool
 	UpCalled        bool
+	ProvisionCalled bool
+	RsyncCalled     bool
 	HaltCalled      bool
 	SuspendCalled   bool
 	SSHConfigCalled bool
+	StatusCalled    bool
 	DestroyCalled   bool
 	PackageCalled   bool
 	VerifyCalled    bool
@@ -18,6 +21,7 @@ type MockVagrantDriver struct {
 
 	ReturnError     error
 	ReturnSSHConfig *VagrantSSHConfig
+	ReturnStatus    string
 	GlobalID        string
 }
 
@@ -36,6 +40,16 @@ func (d *MockVagrantDriver) Up([]string) (string, string, error) {
 	return "", "", nil
 }
 
+func (d *MockVagrantDriver) Provision([]string, func(string)) error {
+	d.ProvisionCalled = true
+	return d.ReturnError
+}
+
+func (d *MockVagrantDriver) Rsync(string) error {
+	d.RsyncCalled = true
+	return d.ReturnError
+}
+
 func (d *MockVagrantDriver) Halt(string) error {
 	d.HaltCalled = true
 	return d.ReturnError
@@ -68,6 +82,11 @@ func (d *MockVagrantDriver) SSHConfig(gid string) (*VagrantSSHConfig, error) {
 	return &sshConfig, d.ReturnError
 }
 
+func (d *MockVagrantDriver) Status(string) (string, error) {
+	d.StatusCalled = true
+	return d.ReturnStatus, d.ReturnError
+}
+
 func (d *MockVagrantDriver) Destroy(string) error {
 	d.DestroyCalled = true
 	return d.ReturnError
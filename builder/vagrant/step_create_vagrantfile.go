@@ -25,21 +25,34 @@ type StepCreateVagrantfile struct {
 	SourceBox              string
 	BoxName                string
 	InsertKey              bool
+	CloudInitUserData      string
+	SyncedFolders          []SyncedFolderConfig
 	defaultTemplateContent string
+	cloudInitPath          string
+	cloudInitContentType   string
 }
 
 type VagrantfileOptions struct {
-	SyncedFolder    string
-	SourceBox       string
-	BoxName         string
-	InsertKey       bool
-	DefaultTemplate string
+	SyncedFolder         string
+	SourceBox            string
+	BoxName              string
+	InsertKey            bool
+	CloudInitUserData    string
+	CloudInitContentType string
+	SyncedFolders        []SyncedFolderConfig
+	DefaultTemplate      string
 }
 
 const DEFAULT_TEMPLATE = `Vagrant.configure("2") do |config|
   config.vm.define "source", autostart: false do |source|
 	source.vm.box = "{{.SourceBox}}"
 	config.ssh.insert_key = {{.InsertKey}}
+	{{- if ne .CloudInitUserData "" }}
+	source.vm.cloud_init :user_data, content_type: "{{.CloudInitContentType}}", path: "{{.CloudInitUserData}}"
+	{{- end }}
+	{{- range .SyncedFolders }}
+	source.vm.synced_folder {{ .RubyArgs }}
+	{{- end }}
   end
   config.vm.define "output" do |output|
 	output.vm.box = "{{.BoxName}}"
@@ -67,6 +80,12 @@ func (s *StepCreateVagrantfile) createVagrantfile() (tplPath string, err error)
 		return
 	}
 
+	if s.CloudInitUserData != "" {
+		if err = s.prepareCloudInit(); err != nil {
+			return
+		}
+	}
+
 	if s.defaultTemplateContent, err = s.renderDefaultTemplate(); err != nil {
 		return
 	}
@@ -85,13 +104,63 @@ func (s *StepCreateVagrantfile) createVagrantfile() (tplPath string, err error)
 	return
 }
 
+// prepareCloudInit works out where the cloud-init user data lives and what
+// kind of content it is. CloudInitUserData is either the user data itself,
+// as told by isInlineUserData, in which case it is written next to the
+// Vagrantfile so Vagrant can read it from disk, or the path to a file.
+func (s *StepCreateVagrantfile) prepareCloudInit() error {
+	var content []byte
+	if isInlineUserData(s.CloudInitUserData) {
+		path, err := filepath.Abs(filepath.Join(s.OutputDir, "user-data"))
+		if err != nil {
+			return err
+		}
+		content = []byte(s.CloudInitUserData)
+		if err := os.WriteFile(path, content, 0644); err != nil {
+			return fmt.Errorf("Error writing cloud-init user data: %s", err)
+		}
+		s.cloudInitPath = path
+	} else {
+		var err error
+		s.cloudInitPath, err = filepath.Abs(s.CloudInitUserData)
+		if err != nil {
+			return err
+		}
+		content, err = os.ReadFile(s.cloudInitPath)
+		if err != nil {
+			return fmt.Errorf("Error reading cloud-init user data: %s", err)
+		}
+	}
+	s.cloudInitPath = filepath.ToSlash(s.cloudInitPath)
+
+	// Vagrant only accepts a handful of MIME types for user data; scripts
+	// are recognised by their shebang, anything else is treated as
+	// cloud-config.
+	s.cloudInitContentType = "text/cloud-config"
+	if strings.HasPrefix(string(content), "#!") {
+		s.cloudInitContentType = "text/x-shellscript"
+	}
+	return nil
+}
+
+// isInlineUserData tells whether cloud-init user data is given inline rather
+// than as the path to a file. cloud-init requires user data to start with
+// the header of its format, like #cloud-config or #!, or to be a MIME
+// multi-part archive, which no path does.
+func isInlineUserData(data string) bool {
+	return strings.HasPrefix(data, "#") || strings.HasPrefix(data, "Content-Type:")
+}
+
 func (s *StepCreateVagrantfile) executeTemplate(tpl *template.Template, file io.Writer) error {
 	opts := &VagrantfileOptions{
-		SyncedFolder:    s.SyncedFolder,
-		BoxName:         s.BoxName,
-		SourceBox:       s.SourceBox,
-		InsertKey:       s.InsertKey,
-		DefaultTemplate: s.defaultTemplateContent,
+		SyncedFolder:         s.SyncedFolder,
+		BoxName:              s.BoxName,
+		SourceBox:            s.SourceBox,
+		InsertKey:            s.InsertKey,
+		CloudInitUserData:    s.cloudInitPath,
+		CloudInitContentType: s.cloudInitContentType,
+		SyncedFolders:        s.SyncedFolders,
+		DefaultTemplate:      s.defaultTemplateContent,
 	}
 	return tpl.Execute(file, opts)
 }
@@ -176,3 +176,123 @@ end`
 		t.Fatalf("EXPECTED: \n%s\n\n RECEIVED: \n%s\n\n", expected, actual)
 	}
 }
+
+func TestCreateFile_cloudInit(t *testing.T) {
+	workdir := t.TempDir()
+	testy := StepCreateVagrantfile{
+		OutputDir:         workdir,
+		SourceBox:         "apples",
+		BoxName:           "bananas",
+		CloudInitUserData: "#cloud-config\npackages:\n  - git\n",
+	}
+	templatePath, err := testy.createVagrantfile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	contents, err := ioutil.ReadFile(templatePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	userData := filepath.ToSlash(filepath.Join(workdir, "user-data"))
+	actual := string(contents)
+	expected := `Vagrant.configure("2") do |config|
+  config.vm.define "source", autostart: false do |source|
+	source.vm.box = "apples"
+	config.ssh.insert_key = false
+	source.vm.cloud_init :user_data, content_type: "text/cloud-config", path: "` + userData + `"
+  end
+  config.vm.define "output" do |output|
+	output.vm.box = "bananas"
+	output.vm.box_url = "file://package.box"
+	config.ssh.insert_key = false
+  end
+  config.vm.synced_folder ".", "/vagrant", disabled: true
+end`
+	if ok := strings.Compare(actual, expected); ok != 0 {
+		t.Fatalf("EXPECTED: \n%s\n\n RECEIVED: \n%s\n\n", expected, actual)
+	}
+	written, err := ioutil.ReadFile(filepath.Join(workdir, "user-data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(written) != testy.CloudInitUserData {
+		t.Fatalf("unexpected user data written: %q", written)
+	}
+}
+
+func TestCreateFile_cloudInitScriptFile(t *testing.T) {
+	workdir := t.TempDir()
+	script := filepath.Join(workdir, "init.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\necho hello\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	testy := StepCreateVagrantfile{
+		OutputDir:         workdir,
+		CloudInitUserData: script,
+	}
+	if _, err := testy.createVagrantfile(); err != nil {
+		t.Fatal(err)
+	}
+	if testy.cloudInitContentType != "text/x-shellscript" {
+		t.Fatalf("expected shell script content type, got %q", testy.cloudInitContentType)
+	}
+	if testy.cloudInitPath != filepath.ToSlash(script) {
+		t.Fatalf("expected user data to be read from %q, got %q", script, testy.cloudInitPath)
+	}
+	if _, err := os.Stat(filepath.Join(workdir, "user-data")); err == nil {
+		t.Fatalf("user data file should not have been written")
+	}
+}
+
+func TestCreateFile_cloudInitMissingFile(t *testing.T) {
+	workdir := t.TempDir()
+	testy := StepCreateVagrantfile{
+		OutputDir:         workdir,
+		CloudInitUserData: filepath.Join(workdir, "user-data.yml"),
+	}
+	if _, err := testy.createVagrantfile(); err == nil {
+		t.Fatal("a missing user data file should be an error")
+	}
+	if _, err := os.Stat(filepath.Join(workdir, "user-data")); err == nil {
+		t.Fatalf("the path should not have been written as user data")
+	}
+}
+
+func TestCreateFile_syncedFolders(t *testing.T) {
+	testy := StepCreateVagrantfile{
+		OutputDir: "./",
+		SourceBox: "apples",
+		BoxName:   "bananas",
+		SyncedFolders: []SyncedFolderConfig{
+			{HostPath: "/src", GuestPath: "/opt/src", Type: "rsync"},
+			{HostPath: "/data", GuestPath: "/data", Options: map[string]string{"owner": "vagrant"}},
+		},
+	}
+	templatePath, err := testy.createVagrantfile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(templatePath)
+	contents, err := ioutil.ReadFile(templatePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual := string(contents)
+	expected := `Vagrant.configure("2") do |config|
+  config.vm.define "source", autostart: false do |source|
+	source.vm.box = "apples"
+	config.ssh.insert_key = false
+	source.vm.synced_folder "/src", "/opt/src", type: "rsync"
+	source.vm.synced_folder "/data", "/data", owner: "vagrant"
+  end
+  config.vm.define "output" do |output|
+	output.vm.box = "bananas"
+	output.vm.box_url = "file://package.box"
+	config.ssh.insert_key = false
+  end
+  config.vm.synced_folder ".", "/vagrant", disabled: true
+end`
+	if ok := strings.Compare(actual, expected); ok != 0 {
+		t.Fatalf("EXPECTED: \n%s\n\n RECEIVED: \n%s\n\n", expected, actual)
+	}
+}
@@ -0,0 +1,20 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package vagrant
+
+import (
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/multistep/commonsteps"
+)
+
+// StepKeepOutputDir sets up the output directory just like
+// commonsteps.StepOutputDir, but never removes it again. With keep_machine the
+// directory holds the Vagrant state of a machine that outlives the build, so
+// it has to survive a failed or cancelled build too.
+type StepKeepOutputDir struct {
+	*commonsteps.StepOutputDir
+}
+
+func (s *StepKeepOutputDir) Cleanup(state multistep.StateBag) {
+}
@@ -5,6 +5,9 @@ package vagrant
 
 import (
 	"context"
+	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
@@ -16,6 +19,37 @@ type StepPackage struct {
 	Include     []string
 	Vagrantfile string
 	GlobalID    string
+	BaseVM      string
+	// Synced folders used during the build, which are disabled in the
+	// Vagrantfile embedded in the box.
+	SyncedFolders []SyncedFolderConfig
+	OutputDir     string
+
+	packageVagrantfile string
+}
+
+func (s *StepPackage) generateArgs() []string {
+	packageArgs := []string{}
+	if s.BaseVM != "" {
+		// Package a VM that Vagrant doesn't manage itself
+		packageArgs = append(packageArgs, "--base", s.BaseVM)
+	} else {
+		box := "source"
+		if s.GlobalID != "" {
+			box = s.GlobalID
+		}
+		packageArgs = append(packageArgs, box)
+	}
+
+	if len(s.Include) > 0 {
+		packageArgs = append(packageArgs, "--include", strings.Join(s.Include, ","))
+	}
+	if s.packageVagrantfile != "" {
+		packageArgs = append(packageArgs, "--vagrantfile", s.packageVagrantfile)
+	} else if s.Vagrantfile != "" {
+		packageArgs = append(packageArgs, "--vagrantfile", s.Vagrantfile)
+	}
+	return packageArgs
 }
 
 func (s *StepPackage) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
@@ -26,29 +60,55 @@ func (s *StepPackage) Run(ctx context.Context, state multistep.StateBag) multist
 		ui.Say("skip_package flag set; not going to call Vagrant package on this box.")
 		return multistep.ActionContinue
 	}
+	if len(s.SyncedFolders) > 0 {
+		path, err := s.writePackageVagrantfile()
+		if err != nil {
+			state.Put("error", fmt.Errorf("Error creating the Vagrantfile to package: %s", err))
+			return multistep.ActionHalt
+		}
+		s.packageVagrantfile = path
+	}
+
 	ui.Say("Packaging box...")
-	packageArgs := []string{}
-	box := "source"
-	if s.GlobalID != "" {
-		box = s.GlobalID
+	err := driver.Package(s.generateArgs())
+	if err != nil {
+		state.Put("error", err)
+		return multistep.ActionHalt
 	}
 
-	packageArgs = append(packageArgs, box)
+	return multistep.ActionContinue
+}
 
-	if len(s.Include) > 0 {
-		packageArgs = append(packageArgs, "--include", strings.Join(s.Include, ","))
-	}
+// writePackageVagrantfile writes the Vagrantfile to embed in the box: the
+// user's output_vagrantfile, if any, followed by a block disabling the synced
+// folders of the build so users of the box don't inherit them.
+func (s *StepPackage) writePackageVagrantfile() (string, error) {
+	content := ""
 	if s.Vagrantfile != "" {
-		packageArgs = append(packageArgs, "--vagrantfile", s.Vagrantfile)
+		b, err := os.ReadFile(s.Vagrantfile)
+		if err != nil {
+			return "", err
+		}
+		content = strings.TrimRight(string(b), "\n") + "\n\n"
 	}
+	content += "Vagrant.configure(\"2\") do |config|\n"
+	for _, f := range s.SyncedFolders {
+		// Vagrant tells synced folders apart by their id, which defaults to
+		// their guest path
+		if id, ok := f.Options["id"]; ok {
+			content += fmt.Sprintf("  config.vm.synced_folder \".\", %s, id: %s, disabled: true\n",
+				rubyString(f.GuestPath), rubyValue(id))
+		} else {
+			content += fmt.Sprintf("  config.vm.synced_folder \".\", %s, disabled: true\n", rubyString(f.GuestPath))
+		}
+	}
+	content += "end\n"
 
-	err := driver.Package(packageArgs)
+	path, err := filepath.Abs(filepath.Join(s.OutputDir, "Vagrantfile.package"))
 	if err != nil {
-		state.Put("error", err)
-		return multistep.ActionHalt
+		return "", err
 	}
-
-	return multistep.ActionContinue
+	return path, os.WriteFile(path, []byte(content), 0644)
 }
 
 func (s *StepPackage) Cleanup(state multistep.StateBag) {
@@ -0,0 +1,97 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package vagrant
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+func TestStepPackage_Impl(t *testing.T) {
+	var raw interface{}
+	raw = new(StepPackage)
+	if _, ok := raw.(multistep.Step); !ok {
+		t.Fatalf("initialize should be a step")
+	}
+}
+
+func TestPrepPackageArgs(t *testing.T) {
+	type testArgs struct {
+		Step     StepPackage
+		Expected []string
+	}
+	tests := []testArgs{
+		{
+			Step:     StepPackage{},
+			Expected: []string{"source"},
+		},
+		{
+			Step: StepPackage{
+				GlobalID:    "a3559ec",
+				Include:     []string{"/tmp/a", "/tmp/b"},
+				Vagrantfile: "/tmp/Vagrantfile",
+			},
+			Expected: []string{"a3559ec", "--include", "/tmp/a,/tmp/b", "--vagrantfile", "/tmp/Vagrantfile"},
+		},
+		{
+			Step: StepPackage{
+				BaseVM: "golden-vm",
+			},
+			Expected: []string{"--base", "golden-vm"},
+		},
+	}
+	for _, test := range tests {
+		args := test.Step.generateArgs()
+		if strings.Join(args, " ") != strings.Join(test.Expected, " ") {
+			t.Fatalf("expected %#v but received %#v", test.Expected, args)
+		}
+	}
+}
+
+func TestStepPackage_packageVagrantfile(t *testing.T) {
+	outputDir := t.TempDir()
+	userVagrantfile := filepath.Join(outputDir, "Vagrantfile.user")
+	err := os.WriteFile(userVagrantfile, []byte("Vagrant.configure(\"2\") do |config|\n  config.vm.hostname = \"box\"\nend\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	step := StepPackage{
+		Vagrantfile: userVagrantfile,
+		OutputDir:   outputDir,
+		SyncedFolders: []SyncedFolderConfig{
+			{HostPath: "/src", GuestPath: "/opt/src", Type: "rsync"},
+			{HostPath: "/data", GuestPath: "/data", Options: map[string]string{"id": "data", "owner": "vagrant"}},
+		},
+	}
+	path, err := step.writePackageVagrantfile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `Vagrant.configure("2") do |config|
+  config.vm.hostname = "box"
+end
+
+Vagrant.configure("2") do |config|
+  config.vm.synced_folder ".", "/opt/src", disabled: true
+  config.vm.synced_folder ".", "/data", id: "data", disabled: true
+end
+`
+	if string(contents) != expected {
+		t.Fatalf("EXPECTED: \n%s\n\n RECEIVED: \n%s\n\n", expected, contents)
+	}
+
+	step.packageVagrantfile = path
+	args := step.generateArgs()
+	if args[len(args)-1] != path {
+		t.Fatalf("expected the generated Vagrantfile to be packaged, got %#v", args)
+	}
+}
@@ -0,0 +1,38 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package vagrant
+
+import (
+	"context"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// StepRsync pushes the contents of rsync synced folders to the guest again,
+// since Vagrant only does so on its own when the machine is brought up.
+type StepRsync struct {
+	GlobalID string
+}
+
+func (s *StepRsync) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	driver := state.Get("driver").(VagrantDriver)
+	ui := state.Get("ui").(packersdk.Ui)
+
+	box := "source"
+	if s.GlobalID != "" {
+		box = s.GlobalID
+	}
+
+	ui.Say("Syncing rsync folders to the guest...")
+	if err := driver.Rsync(box); err != nil {
+		state.Put("error", err)
+		return multistep.ActionHalt
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *StepRsync) Cleanup(state multistep.StateBag) {
+}
@@ -6,15 +6,26 @@ package vagrant
 import (
 	"context"
 	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 )
 
+// The file in the output directory where keep_machine records the id of the
+// source machine, so later builds can find it again.
+const machineIDFile = "packer_machine_id"
+
 type StepUp struct {
 	TeardownMethod string
 	Provider       string
 	GlobalID       string
+	KeepMachine    bool
+	OutputDir      string
+	NoProvision    bool
 }
 
 func (s *StepUp) generateArgs() []string {
@@ -29,6 +40,10 @@ func (s *StepUp) generateArgs() []string {
 	if s.Provider != "" {
 		args = append(args, fmt.Sprintf("--provider=%s", s.Provider))
 	}
+	if s.NoProvision {
+		// Vagrant provisioners run in their own step
+		args = append(args, "--no-provision")
+	}
 	return args
 }
 
@@ -49,6 +64,15 @@ func (s *StepUp) Run(ctx context.Context, state multistep.StateBag) multistep.St
 		return multistep.ActionHalt
 	}
 
+	if s.KeepMachine && s.GlobalID == "" {
+		id, err := recordMachineID(s.OutputDir)
+		if err != nil {
+			state.Put("error", fmt.Errorf("Error recording the id of the source machine: %s", err))
+			return multistep.ActionHalt
+		}
+		log.Printf("Recorded source machine id %s for later builds", id)
+	}
+
 	return multistep.ActionContinue
 }
 
@@ -56,6 +80,11 @@ func (s *StepUp) Cleanup(state multistep.StateBag) {
 	driver := state.Get("driver").(VagrantDriver)
 	ui := state.Get("ui").(packersdk.Ui)
 
+	if s.KeepMachine {
+		ui.Say("keep_machine flag set; leaving the Vagrant machine as is for the next build.")
+		return
+	}
+
 	ui.Say(fmt.Sprintf("%sing Vagrant box...", s.TeardownMethod))
 
 	box := "source"
@@ -78,3 +107,32 @@ func (s *StepUp) Cleanup(state multistep.StateBag) {
 		state.Put("error", fmt.Errorf("Error halting Vagrant machine; please try to do this manually"))
 	}
 }
+
+// recordMachineID looks up the id Vagrant gave the source machine in the
+// output directory and stores it in machineIDFile.
+func recordMachineID(outputDir string) (string, error) {
+	matches, err := filepath.Glob(filepath.Join(outputDir, ".vagrant", "machines", "source", "*", "index_uuid"))
+	if err != nil {
+		return "", err
+	}
+	if len(matches) == 0 {
+		return "", fmt.Errorf("no machine index found in %s", outputDir)
+	}
+	id, err := os.ReadFile(matches[0])
+	if err != nil {
+		return "", err
+	}
+	machineID := strings.TrimSpace(string(id))
+	err = os.WriteFile(filepath.Join(outputDir, machineIDFile), []byte(machineID+"\n"), 0644)
+	return machineID, err
+}
+
+// readMachineID returns the machine id recorded by a previous keep_machine
+// build, or an empty string if there is none.
+func readMachineID(outputDir string) string {
+	id, err := os.ReadFile(filepath.Join(outputDir, machineIDFile))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(id))
+}
@@ -4,8 +4,14 @@
 package vagrant
 
 import (
+	"context"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 )
 
 func TestPrepUpArgs(t *testing.T) {
@@ -31,6 +37,12 @@ func TestPrepUpArgs(t *testing.T) {
 			},
 			Expected: []string{"source", "--provider=pro"},
 		},
+		{
+			Step: StepUp{
+				NoProvision: true,
+			},
+			Expected: []string{"source", "--no-provision"},
+		},
 	}
 	for _, test := range tests {
 		args := test.Step.generateArgs()
@@ -41,3 +53,40 @@ func TestPrepUpArgs(t *testing.T) {
 		}
 	}
 }
+
+func TestStepUp_KeepMachine(t *testing.T) {
+	outputDir := t.TempDir()
+	indexDir := filepath.Join(outputDir, ".vagrant", "machines", "source", "virtualbox")
+	if err := os.MkdirAll(indexDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	uuid := "a3559ec2d8f04b1b9a3f1c6bd0e7e5f1"
+	if err := os.WriteFile(filepath.Join(indexDir, "index_uuid"), []byte(uuid), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	driver := &MockVagrantDriver{}
+	state := new(multistep.BasicStateBag)
+	state.Put("driver", driver)
+	state.Put("ui", &packersdk.BasicUi{
+		Reader: new(strings.Reader),
+		Writer: new(strings.Builder),
+	})
+
+	step := StepUp{
+		TeardownMethod: "destroy",
+		KeepMachine:    true,
+		OutputDir:      outputDir,
+	}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("unexpected action %#v: %v", action, state.Get("error"))
+	}
+	if id := readMachineID(outputDir); id != uuid {
+		t.Fatalf("expected machine id %q to be recorded, got %q", uuid, id)
+	}
+
+	step.Cleanup(state)
+	if driver.DestroyCalled || driver.HaltCalled || driver.SuspendCalled {
+		t.Fatalf("machine should have been kept")
+	}
+}
@@ -0,0 +1,51 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package vagrant
+
+import (
+	"context"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// StepVagrantProvision runs the provisioners defined in the Vagrantfile, before
+// any of the Packer provisioners run.
+type StepVagrantProvision struct {
+	GlobalID      string
+	ProvisionWith []string
+}
+
+func (s *StepVagrantProvision) generateArgs() []string {
+	box := "source"
+	if s.GlobalID != "" {
+		box = s.GlobalID
+	}
+
+	args := []string{box}
+	if len(s.ProvisionWith) > 0 {
+		args = append(args, "--provision-with", strings.Join(s.ProvisionWith, ","))
+	}
+	return args
+}
+
+func (s *StepVagrantProvision) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	driver := state.Get("driver").(VagrantDriver)
+	ui := state.Get("ui").(packersdk.Ui)
+
+	ui.Say("Running Vagrant provisioners...")
+	err := driver.Provision(s.generateArgs(), func(line string) {
+		ui.Message(line)
+	})
+	if err != nil {
+		state.Put("error", err)
+		return multistep.ActionHalt
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *StepVagrantProvision) Cleanup(state multistep.StateBag) {
+}
@@ -0,0 +1,45 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package vagrant
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+func TestStepVagrantProvision_Impl(t *testing.T) {
+	var raw interface{}
+	raw = new(StepVagrantProvision)
+	if _, ok := raw.(multistep.Step); !ok {
+		t.Fatalf("initialize should be a step")
+	}
+}
+
+func TestPrepVagrantProvisionArgs(t *testing.T) {
+	type testArgs struct {
+		Step     StepVagrantProvision
+		Expected []string
+	}
+	tests := []testArgs{
+		{
+			Step:     StepVagrantProvision{},
+			Expected: []string{"source"},
+		},
+		{
+			Step: StepVagrantProvision{
+				GlobalID:      "a3559ec",
+				ProvisionWith: []string{"shell", "ansible_local"},
+			},
+			Expected: []string{"a3559ec", "--provision-with", "shell,ansible_local"},
+		},
+	}
+	for _, test := range tests {
+		args := test.Step.generateArgs()
+		if strings.Join(args, " ") != strings.Join(test.Expected, " ") {
+			t.Fatalf("expected %#v but received %#v", test.Expected, args)
+		}
+	}
+}
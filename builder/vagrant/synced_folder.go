@@ -0,0 +1,118 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type SyncedFolderConfig
+
+package vagrant
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A folder to be synced between the Packer host and the source machine,
+// equivalent to a `config.vm.synced_folder` line in the Vagrantfile.
+type SyncedFolderConfig struct {
+	// Path to the folder on the Packer host. The path can be absolute or
+	// relative to the directory Packer is being run from.
+	HostPath string `mapstructure:"host_path" required:"true"`
+	// Path the folder is mounted at inside of the guest.
+	GuestPath string `mapstructure:"guest_path" required:"true"`
+	// The type of synced folder, such as "rsync", "nfs" or "smb". If unset,
+	// Vagrant picks the best type for the provider.
+	Type string `mapstructure:"type" required:"false"`
+	// Any other options to set on the synced folder, for example `owner` or
+	// `mount_options`. Values are written to the Vagrantfile as Ruby values:
+	// `true`, `false`, `nil` and numbers as is, values starting with `[` or `:`
+	// verbatim, and anything else as a string.
+	Options map[string]string `mapstructure:"options" required:"false"`
+}
+
+var rubySymbolRe = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+var rubyIntRe = regexp.MustCompile(`^-?[0-9]+$`)
+
+func (f *SyncedFolderConfig) Prepare() []error {
+	var errs []error
+
+	if f.HostPath == "" {
+		errs = append(errs, fmt.Errorf("host_path must be set for each synced folder"))
+	} else {
+		if strings.HasPrefix(f.HostPath, "~/") {
+			homedir, _ := os.UserHomeDir()
+			f.HostPath = filepath.Join(homedir, f.HostPath[2:])
+		}
+		path, err := filepath.Abs(f.HostPath)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("unable to determine absolute path for synced folder: %s", f.HostPath))
+		} else {
+			f.HostPath = path
+		}
+		if _, err := os.Stat(f.HostPath); err != nil {
+			errs = append(errs, fmt.Errorf("synced folder \"%s\" does not exist on the Packer host.", f.HostPath))
+		}
+	}
+	if f.GuestPath == "" {
+		errs = append(errs, fmt.Errorf("guest_path must be set for synced folder %q", f.HostPath))
+	}
+	for key := range f.Options {
+		if !rubySymbolRe.MatchString(key) {
+			errs = append(errs, fmt.Errorf("invalid option %q for synced folder %q", key, f.HostPath))
+		}
+	}
+
+	return errs
+}
+
+// RubyArgs renders the folder as the arguments of a synced_folder call, for
+// use in Vagrantfile templates.
+func (f SyncedFolderConfig) RubyArgs() string {
+	out := fmt.Sprintf("%s, %s", rubyString(filepath.ToSlash(f.HostPath)), rubyString(f.GuestPath))
+	if f.Type != "" {
+		out += fmt.Sprintf(", type: %s", rubyString(f.Type))
+	}
+	keys := make([]string, 0, len(f.Options))
+	for key := range f.Options {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		out += fmt.Sprintf(", %s: %s", key, rubyValue(f.Options[key]))
+	}
+	return out
+}
+
+func rubyValue(value string) string {
+	switch {
+	case value == "true", value == "false", value == "nil":
+		return value
+	case rubyIntRe.MatchString(value):
+		return value
+	case strings.HasPrefix(value, "["), strings.HasPrefix(value, ":"):
+		return value
+	}
+	return rubyString(value)
+}
+
+// rubyString quotes a string so Ruby reads it back as is, without
+// interpolating anything.
+func rubyString(value string) string {
+	quoted := strconv.Quote(value)
+	return strings.ReplaceAll(quoted, "#", `\#`)
+}
+
+// usesRsync tells whether any of the folders needs `vagrant rsync` to be kept
+// up to date.
+func usesRsync(folders []SyncedFolderConfig) bool {
+	for _, f := range folders {
+		if f.Type == "rsync" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,37 @@
+// Code generated by "packer-sdc mapstructure-to-hcl2"; DO NOT EDIT.
+
+package vagrant
+
+import (
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// FlatSyncedFolderConfig is an auto-generated flat version of SyncedFolderConfig.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatSyncedFolderConfig struct {
+	HostPath  *string           `mapstructure:"host_path" required:"true" cty:"host_path" hcl:"host_path"`
+	GuestPath *string           `mapstructure:"guest_path" required:"true" cty:"guest_path" hcl:"guest_path"`
+	Type      *string           `mapstructure:"type" required:"false" cty:"type" hcl:"type"`
+	Options   map[string]string `mapstructure:"options" required:"false" cty:"options" hcl:"options"`
+}
+
+// FlatMapstructure returns a new FlatSyncedFolderConfig.
+// FlatSyncedFolderConfig is an auto-generated flat version of SyncedFolderConfig.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*SyncedFolderConfig) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatSyncedFolderConfig)
+}
+
+// HCL2Spec returns the hcl spec of a SyncedFolderConfig.
+// This spec is used by HCL to read the fields of SyncedFolderConfig.
+// The decoded values from this spec will then be applied to a FlatSyncedFolderConfig.
+func (*FlatSyncedFolderConfig) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"host_path":  &hcldec.AttrSpec{Name: "host_path", Type: cty.String, Required: false},
+		"guest_path": &hcldec.AttrSpec{Name: "guest_path", Type: cty.String, Required: false},
+		"type":       &hcldec.AttrSpec{Name: "type", Type: cty.String, Required: false},
+		"options":    &hcldec.AttrSpec{Name: "options", Type: cty.Map(cty.String), Required: false},
+	}
+	return s
+}
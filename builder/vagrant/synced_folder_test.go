@@ -0,0 +1,48 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package vagrant
+
+import (
+	"testing"
+)
+
+func TestSyncedFolderConfig_Prepare(t *testing.T) {
+	c := SyncedFolderConfig{
+		HostPath:  ".",
+		GuestPath: "/opt/src",
+		Options:   map[string]string{"owner": "vagrant"},
+	}
+	if errs := c.Prepare(); len(errs) != 0 {
+		t.Fatalf("should not have errors: %v", errs)
+	}
+
+	c = SyncedFolderConfig{
+		HostPath: "./does-not-exist",
+		Options:  map[string]string{"mount-options": "ro"},
+	}
+	if errs := c.Prepare(); len(errs) != 3 {
+		t.Fatalf("expected missing host path, missing guest path and invalid option errors, got %v", errs)
+	}
+}
+
+func TestSyncedFolderConfig_RubyArgs(t *testing.T) {
+	c := SyncedFolderConfig{
+		HostPath:  "/src",
+		GuestPath: "/opt/src",
+		Type:      "rsync",
+		Options: map[string]string{
+			"rsync__exclude": `[".git/"]`,
+			"create":         "true",
+			"owner":          "#{user}",
+			"mount_options":  `["ro"]`,
+			"id":             ":src",
+			"nfs_version":    "4",
+		},
+	}
+	expected := `"/src", "/opt/src", type: "rsync", create: true, id: :src, mount_options: ["ro"], ` +
+		`nfs_version: 4, owner: "\#{user}", rsync__exclude: [".git/"]`
+	if actual := c.RubyArgs(); actual != expected {
+		t.Fatalf("EXPECTED: \n%s\n\n RECEIVED: \n%s\n\n", expected, actual)
+	}
+}
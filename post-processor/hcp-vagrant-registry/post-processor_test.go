@@ -9,12 +9,14 @@ import (
 	"compress/gzip"
 	"context"
 	"fmt"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"testing"
 
 	"github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/rpc"
 	"github.com/stretchr/testify/require"
 )
 
@@ -160,6 +162,7 @@ func TestPostProcessor(t *testing.T) {
 			files   tarFiles
 			stack   []stubResponse
 			setup   func(config map[string]interface{}, artifact *packer.MockArtifact)
+			state   map[string]interface{}
 			wantErr string
 		}{
 			{
@@ -232,6 +235,68 @@ func TestPostProcessor(t *testing.T) {
 					c["architecture"] = "amd64"
 				},
 			},
+			{
+				desc: "OK - artifact state over RPC",
+				stack: []stubResponse{
+					{
+						Method:     "POST",
+						Path:       "/oauth2/token",
+						Response:   `{"access_token": "TEST_TOKEN", "expiry": 0}`,
+						StatusCode: 200,
+					},
+					{
+						Method:     "GET",
+						Path:       "/vagrant/2022-09-30/registry/hashicorp/box/precise64",
+						Response:   `{}`,
+						StatusCode: 200,
+					},
+					{
+						Method:     "GET",
+						Path:       "/vagrant/2022-09-30/registry/hashicorp/box/precise64/version/0.5",
+						Response:   `{"version": {"state": "UNRELEASED"}}`,
+						StatusCode: 200,
+					},
+					{
+						Method:     "GET",
+						Path:       "/vagrant/2022-09-30/registry/hashicorp/box/precise64/version/0.5/provider/virtualbox",
+						Response:   `{}`,
+						StatusCode: 200,
+					},
+					{
+						Method:     "GET",
+						Path:       "/vagrant/2022-09-30/registry/hashicorp/box/precise64/version/0.5/provider/virtualbox/architecture/amd64",
+						Response:   `{}`,
+						StatusCode: 200,
+					},
+					{
+						Method:     "PUT",
+						Path:       "/vagrant/2022-09-30/registry/hashicorp/box/precise64/version/0.5/provider/virtualbox/architecture/amd64",
+						Response:   `{}`,
+						StatusCode: 200,
+					},
+					{
+						Method:     "GET",
+						Path:       "/vagrant/2022-09-30/registry/hashicorp/box/precise64/version/0.5/provider/virtualbox/architecture/amd64/upload",
+						Response:   fmt.Sprintf(`{"url": "%s"}`, uploadURL),
+						StatusCode: 200,
+					},
+					{
+						Method:     "PUT",
+						Path:       "/vagrant/2022-09-30/registry/hashicorp/box/precise64/version/0.5/release",
+						Response:   `{}`,
+						StatusCode: 200,
+					},
+				},
+				files: tarFiles{
+					{"foo.txt", "This is a foo file"},
+					{"bar.txt", "This is a bar file"},
+					{"metadata.json", `{"provider": "virtualbox"}`},
+				},
+				state: map[string]interface{}{
+					"metadata":       `{"provider": "virtualbox", "architecture": "amd64"}`,
+					"generated_data": map[string]string{"BuildName": "test"},
+				},
+			},
 			{
 				desc: "OK - architecture metadata",
 				stack: []stubResponse{
@@ -924,6 +989,7 @@ func TestPostProcessor(t *testing.T) {
 					BuilderIdValue: "mitchellh.post-processor.vagrant",
 					FilesValue:     []string{boxfile.Name()},
 					IdValue:        "virtualbox",
+					StateValues:    tc.state,
 				}
 				server := newStackServer(tc.stack)
 				defer server.Close()
@@ -938,7 +1004,14 @@ func TestPostProcessor(t *testing.T) {
 				var p PostProcessor
 				require.NoError(p.Configure(config), "failed to configure post processor")
 
-				_, _, _, err = p.PostProcess(ctx, testUi(), artifact)
+				// The state is read the way it is when the artifact comes
+				// from the vagrant post-processor
+				var input packer.Artifact = artifact
+				if tc.state != nil {
+					input = rpcArtifact(t, artifact)
+				}
+
+				_, _, _, err = p.PostProcess(ctx, testUi(), input)
 				if tc.wantErr != "" {
 					require.Error(err)
 					require.ErrorContains(err, tc.wantErr)
@@ -950,6 +1023,40 @@ func TestPostProcessor(t *testing.T) {
 	})
 }
 
+// rpcArtifact serves the artifact over the plugin RPC, and returns the client
+// side of it.
+func rpcArtifact(t *testing.T, artifact packer.Artifact) packer.Artifact {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		server, err := rpc.NewServer(conn)
+		if err != nil {
+			return
+		}
+		server.RegisterArtifact(artifact)
+		server.Serve()
+	}()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := rpc.NewClient(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client.Artifact()
+}
+
 func newBoxFile() (boxfile *os.File, err error) {
 	boxfile, err = os.CreateTemp(os.TempDir(), "test*.box")
 	if err != nil {
@@ -20,6 +20,8 @@ func (s *stepCreateArchitecture) Run(ctx context.Context, state multistep.StateB
 	downloadUrl := state.Get("downloadUrl").(string)
 	config := state.Get("config").(*Config)
 	archName := state.Get("architecture").(string)
+	checksumType := state.Get("checksumType").(string)
+	checksum := state.Get("checksum").(string)
 
 	resp, err := client.ReadArchitecture(
 		&registry_service.ReadArchitectureParams{
@@ -50,10 +52,10 @@ func (s *stepCreateArchitecture) Run(ctx context.Context, state multistep.StateB
 		data.DownloadURL = downloadUrl
 	}
 
-	if config.BoxChecksum != "" {
-		data.Checksum = config.checksum
+	if checksum != "" {
+		data.Checksum = checksum
 		data.ChecksumType = models.NewHashicorpCloudVagrant20220930ChecksumType(
-			models.HashicorpCloudVagrant20220930ChecksumType(config.checksumType),
+			models.HashicorpCloudVagrant20220930ChecksumType(checksumType),
 		)
 	} else {
 		data.ChecksumType = models.HashicorpCloudVagrant20220930ChecksumTypeNONE.Pointer()
@@ -152,14 +152,23 @@ func (p *PostProcessor) PostProcess(ctx context.Context, ui packersdk.Ui, artifa
 		ui.Message("Warning: Using Vagrant Cloud token found in ATLAS_TOKEN. Please make sure it is correct, or set VAGRANT_CLOUD_TOKEN")
 	}
 
-	var boxMetadata map[string]interface{}
-	var err error
+	// The vagrant post-processor hands over the metadata of the box, which
+	// saves unpacking the whole box to read it
+	boxMetadata, err := stateMetadata(artifact)
+	if err != nil {
+		return nil, false, false, err
+	}
 
 	// Get the architecture
 	archName := p.config.Architecture
 	if archName == "" {
-		if boxMetadata, err = metadataFromVagrantBox(artifact.Files()[0]); err != nil {
-			return nil, false, false, err
+		archName, _ = artifact.State("architecture").(string)
+	}
+	if archName == "" {
+		if boxMetadata == nil {
+			if boxMetadata, err = metadataFromVagrantBox(artifact.Files()[0]); err != nil {
+				return nil, false, false, err
+			}
 		}
 		if archName, err = getArchitecture(boxMetadata); err != nil {
 			return nil, false, false, err
@@ -167,21 +176,17 @@ func (p *PostProcessor) PostProcess(ctx context.Context, ui packersdk.Ui, artifa
 	}
 
 	// Determine the name of the provider for Vagrant Cloud, and Vagrant
-	providerName, err := getProvider(artifact.Id(), artifact.Files()[0], builtins[artifact.BuilderId()], boxMetadata)
-	if err != nil {
-		return nil, false, false, fmt.Errorf("error getting provider name: %s", err)
+	providerName, _ := artifact.State("provider").(string)
+	if providerName == "" {
+		providerName, err = getProvider(artifact.Id(), artifact.Files()[0], builtins[artifact.BuilderId()], boxMetadata)
+		if err != nil {
+			return nil, false, false, fmt.Errorf("error getting provider name: %s", err)
+		}
 	}
 
-	var generatedData map[interface{}]interface{}
-	stateData := artifact.State("generated_data")
-	if stateData != nil {
-		// Make sure it's not a nil map so we can assign to it later.
-		generatedData = stateData.(map[interface{}]interface{})
-	}
-	// If stateData has a nil map generatedData will be nil
-	// and we need to make sure it's not
-	if generatedData == nil {
-		generatedData = make(map[interface{}]interface{})
+	generatedData := make(map[interface{}]interface{})
+	for k, v := range stateMap(artifact.State("generated_data")) {
+		generatedData[k] = v
 	}
 	generatedData["ArtifactId"] = artifact.Id()
 	generatedData["Provider"] = providerName
@@ -208,7 +213,7 @@ func (p *PostProcessor) PostProcess(ctx context.Context, ui packersdk.Ui, artifa
 	state.Put("ui", ui)
 	state.Put("providerName", providerName)
 	state.Put("boxDownloadUrl", boxDownloadUrl)
-	state.Put("boxChecksum", p.config.BoxChecksum)
+	state.Put("boxChecksum", boxChecksum(p.config.BoxChecksum, artifact))
 	state.Put("architecture", archName)
 	state.Put("defaultArchitecture", archName == p.config.DefaultArchitecture)
 
@@ -238,6 +243,48 @@ func (p *PostProcessor) PostProcess(ctx context.Context, ui packersdk.Ui, artifa
 	return NewArtifact(providerName, p.config.Tag), true, false, nil
 }
 
+// boxChecksum returns the configured checksum or, when none is set, the
+// sha256 digest computed by the vagrant post-processor while writing the box.
+func boxChecksum(configured string, artifact packersdk.Artifact) string {
+	if configured != "" {
+		return configured
+	}
+	if digest, ok := stateMap(artifact.State("digests"))["sha256"].(string); ok && digest != "" {
+		return "sha256:" + digest
+	}
+	return ""
+}
+
+// stateMetadata decodes the metadata the vagrant post-processor hands over
+// as JSON, if any.
+func stateMetadata(artifact packersdk.Artifact) (map[string]interface{}, error) {
+	data, ok := artifact.State("metadata").(string)
+	if !ok || data == "" {
+		return nil, nil
+	}
+	var metadata map[string]interface{}
+	if err := json.Unmarshal([]byte(data), &metadata); err != nil {
+		return nil, fmt.Errorf("Error decoding the box metadata: %s", err)
+	}
+	return metadata, nil
+}
+
+// stateMap returns a map stored in the state of the artifact. Maps come back
+// as map[interface{}]interface{} when the artifact is read over RPC.
+func stateMap(value interface{}) map[interface{}]interface{} {
+	switch m := value.(type) {
+	case map[interface{}]interface{}:
+		return m
+	case map[string]string:
+		result := make(map[interface{}]interface{}, len(m))
+		for k, v := range m {
+			result[k] = v
+		}
+		return result
+	}
+	return nil
+}
+
 func getArchitecture(metadata map[string]interface{}) (architectureName string, err error) {
 	if arch, ok := metadata["architecture"]; ok {
 		if architectureName, ok = arch.(string); ok && architectureName != "" {
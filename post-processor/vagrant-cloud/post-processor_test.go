@@ -10,6 +10,7 @@ import (
 	"context"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -18,6 +19,7 @@ import (
 	"testing"
 
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/rpc"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -566,6 +568,94 @@ func TestPostProcessor_PostProcess_uploadsAndReleases(t *testing.T) {
 	}
 }
 
+func TestPostProcessor_PostProcess_artifactState(t *testing.T) {
+	files := tarFiles{
+		{"metadata.json", `{"provider": "vmware_desktop"}`},
+	}
+	boxfile, err := createBox(files)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	defer os.Remove(boxfile.Name())
+
+	// Provider and architecture recorded by the vagrant post-processor are
+	// used rather than what is inside of the box, as read over RPC
+	artifact := rpcArtifact(t, &packersdk.MockArtifact{
+		BuilderIdValue: "mitchellh.post-processor.vagrant",
+		FilesValue:     []string{boxfile.Name()},
+		IdValue:        "vmware",
+		StateValues: map[string]interface{}{
+			"metadata":       `{"provider": "virtualbox", "architecture": "arm64"}`,
+			"architecture":   "arm64",
+			"provider":       "virtualbox",
+			"generated_data": map[string]string{"BuildName": "test"},
+		},
+	})
+
+	s := newStackServer([]stubResponse{stubResponse{StatusCode: 200, Method: "PUT", Path: "/box-upload-path"}})
+	defer s.Close()
+
+	stack := []stubResponse{
+		stubResponse{StatusCode: 200, Method: "GET", Path: "/authenticate"},
+		stubResponse{StatusCode: 200, Method: "GET", Path: "/box/hashicorp/precise64", Response: `{"tag": "hashicorp/precise64"}`},
+		stubResponse{StatusCode: 200, Method: "POST", Path: "/box/hashicorp/precise64/versions", Response: `{}`},
+		stubResponse{StatusCode: 200, Method: "POST", Path: "/box/hashicorp/precise64/version/0.5/providers", Response: `{}`},
+		stubResponse{StatusCode: 200, Method: "GET", Path: "/box/hashicorp/precise64/version/0.5/provider/virtualbox/arm64/upload", Response: `{"upload_path": "` + s.URL + `/box-upload-path"}`},
+		stubResponse{StatusCode: 200, Method: "PUT", Path: "/box/hashicorp/precise64/version/0.5/release"},
+	}
+
+	server := newStackServer(stack)
+	defer server.Close()
+	config := testGoodConfig()
+	config["vagrant_cloud_url"] = server.URL
+	config["no_direct_upload"] = true
+
+	var p PostProcessor
+
+	err = p.Configure(config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	_, _, _, err = p.PostProcess(context.Background(), testUi(), artifact)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+// rpcArtifact serves the artifact over the plugin RPC, and returns the client
+// side of it.
+func rpcArtifact(t *testing.T, artifact packersdk.Artifact) packersdk.Artifact {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		server, err := rpc.NewServer(conn)
+		if err != nil {
+			return
+		}
+		server.RegisterArtifact(artifact)
+		server.Serve()
+	}()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := rpc.NewClient(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client.Artifact()
+}
+
 func TestPostProcessor_PostProcess_uploadsAndNoRelease(t *testing.T) {
 	files := tarFiles{
 		{"foo.txt", "This is a foo file"},
@@ -1039,6 +1129,19 @@ func TestGetProvider_other(t *testing.T) {
 	t.Logf("Expected provider '%s'. Got provider '%s'", expectedProvider, provider)
 }
 
+func TestBoxChecksum(t *testing.T) {
+	artifact := &packersdk.MockArtifact{
+		StateValues: map[string]interface{}{
+			"digests": map[string]string{"sha256": "abc123", "sha512": "def456"},
+		},
+	}
+
+	assert.Equal(t, "md5:foo", boxChecksum("md5:foo", artifact), "configured checksum should win")
+	assert.Equal(t, "sha256:abc123", boxChecksum("", artifact), "digest of the box should be used")
+	assert.Equal(t, "sha256:abc123", boxChecksum("", rpcArtifact(t, artifact)), "digest of the box should be read over RPC")
+	assert.Equal(t, "", boxChecksum("", &packersdk.MockArtifact{}), "no checksum should be set")
+}
+
 func newBoxFile() (boxfile *os.File, err error) {
 	boxfile, err = ioutil.TempFile(os.TempDir(), "test*.box")
 	if err != nil {
@@ -0,0 +1,66 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package vagrant
+
+import (
+	"runtime"
+	"strings"
+)
+
+// hostArchitecture returns the architecture of the host running Packer, in
+// the naming of Vagrant.
+func hostArchitecture() string {
+	if mappedArch, ok := vagrantArchMap[runtime.GOARCH]; ok {
+		return mappedArch
+	}
+	return runtime.GOARCH
+}
+
+// vagrantArchitecture maps the names other tools give to architectures to
+// the ones of Vagrant, which are the ones of Go but for i386. It returns an
+// empty string for unknown architectures.
+func vagrantArchitecture(arch string) string {
+	switch arch = strings.ToLower(arch); arch {
+	case "amd64", "x86_64", "x86-64", "x64":
+		return "amd64"
+	case "i386", "i486", "i586", "i686", "x86", "386":
+		return "i386"
+	case "arm64", "aarch64":
+		return "arm64"
+	case "arm", "armv7", "armv7l", "armhf":
+		return "arm"
+	case "ppc64le", "ppc64", "mips64le", "mips64", "mipsle", "mips", "s390x", "riscv64":
+		return arch
+	}
+	return ""
+}
+
+// qemuArchitecture returns the architecture a QEMU binary, like
+// qemu-system-aarch64, emulates.
+func qemuArchitecture(binary string) string {
+	name := strings.TrimSuffix(strings.ToLower(binary), ".exe")
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		name = name[i+1:]
+	}
+	arch, ok := strings.CutPrefix(name, "qemu-system-")
+	if !ok {
+		return ""
+	}
+	return vagrantArchitecture(arch)
+}
+
+// qemuMachineArchitecture returns the architecture of a QEMU machine type,
+// for the ones that only exist on a single architecture.
+func qemuMachineArchitecture(machine string) string {
+	machine = strings.ToLower(machine)
+	switch {
+	case machine == "pc", machine == "q35", strings.HasPrefix(machine, "pc-"):
+		return "amd64"
+	case strings.HasPrefix(machine, "pseries"):
+		return "ppc64le"
+	case strings.HasPrefix(machine, "s390-ccw-virtio"):
+		return "s390x"
+	}
+	return ""
+}
@@ -0,0 +1,53 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package vagrant
+
+import (
+	"testing"
+)
+
+func TestVagrantArchitecture(t *testing.T) {
+	cases := map[string]string{
+		"x86_64":  "amd64",
+		"AMD64":   "amd64",
+		"i686":    "i386",
+		"aarch64": "arm64",
+		"armv7l":  "arm",
+		"s390x":   "s390x",
+		"sparc":   "",
+	}
+	for arch, expected := range cases {
+		if actual := vagrantArchitecture(arch); actual != expected {
+			t.Errorf("%s: expected %q, got %q", arch, expected, actual)
+		}
+	}
+}
+
+func TestQemuArchitecture(t *testing.T) {
+	cases := map[string]string{
+		"qemu-system-aarch64":                          "arm64",
+		"/usr/bin/qemu-system-x86_64":                  "amd64",
+		`C:\Program Files\qemu\qemu-system-x86_64.exe`: "amd64",
+		"qemu-system-ppc64":                            "ppc64",
+		"qemu-kvm":                                     "",
+	}
+	for binary, expected := range cases {
+		if actual := qemuArchitecture(binary); actual != expected {
+			t.Errorf("%s: expected %q, got %q", binary, expected, actual)
+		}
+	}
+
+	machines := map[string]string{
+		"q35":             "amd64",
+		"pc-i440fx-8.2":   "amd64",
+		"pseries-9.0":     "ppc64le",
+		"s390-ccw-virtio": "s390x",
+		"virt":            "",
+	}
+	for machine, expected := range machines {
+		if actual := qemuMachineArchitecture(machine); actual != expected {
+			t.Errorf("%s: expected %q, got %q", machine, expected, actual)
+		}
+	}
+}
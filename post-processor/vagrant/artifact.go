@@ -13,6 +13,14 @@ const BuilderId = "mitchellh.post-processor.vagrant"
 type Artifact struct {
 	Path     string
 	Provider string
+
+	// ChecksumPath is the path of the file holding the sha256 digest of the
+	// box, if any.
+	ChecksumPath string
+
+	// StateData should store data such as the metadata and digests of the
+	// box, to be shared with post-processors
+	StateData map[string]interface{}
 }
 
 func NewArtifact(provider, path string) *Artifact {
@@ -27,7 +35,11 @@ func (*Artifact) BuilderId() string {
 }
 
 func (a *Artifact) Files() []string {
-	return []string{a.Path}
+	files := []string{a.Path}
+	if a.ChecksumPath != "" {
+		files = append(files, a.ChecksumPath)
+	}
+	return files
 }
 
 func (a *Artifact) Id() string {
@@ -39,9 +51,14 @@ func (a *Artifact) String() string {
 }
 
 func (a *Artifact) State(name string) interface{} {
-	return nil
+	return a.StateData[name]
 }
 
 func (a *Artifact) Destroy() error {
+	if a.ChecksumPath != "" {
+		if err := os.Remove(a.ChecksumPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
 	return os.Remove(a.Path)
 }
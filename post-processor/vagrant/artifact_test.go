@@ -4,6 +4,8 @@
 package vagrant
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
@@ -23,3 +25,42 @@ func TestArtifact_Id(t *testing.T) {
 		t.Fatalf("should return name as Id")
 	}
 }
+
+func TestArtifact_ChecksumFile(t *testing.T) {
+	box := filepath.Join(t.TempDir(), "package.box")
+	if err := os.WriteFile(box, []byte("box"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	checksumPath, err := WriteChecksumFile(box, "abc123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	contents, err := os.ReadFile(checksumPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(contents) != "abc123  package.box\n" {
+		t.Fatalf("unexpected checksum file contents: %q", contents)
+	}
+
+	artifact := NewArtifact("virtualbox", box)
+	artifact.ChecksumPath = checksumPath
+	artifact.StateData = map[string]interface{}{
+		"digests": map[string]string{"sha256": "abc123"},
+	}
+	if files := artifact.Files(); len(files) != 2 || files[1] != checksumPath {
+		t.Fatalf("checksum file should be part of the artifact: %v", files)
+	}
+	if digests := artifact.State("digests").(map[string]string); digests["sha256"] != "abc123" {
+		t.Fatalf("unexpected digests: %v", digests)
+	}
+
+	if err := artifact.Destroy(); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range []string{box, checksumPath} {
+		if _, err := os.Stat(f); !os.IsNotExist(err) {
+			t.Fatalf("%s should have been removed", f)
+		}
+	}
+}
@@ -6,6 +6,7 @@ package vagrant
 import (
 	"bytes"
 	"fmt"
+	"sort"
 	"strings"
 	"text/template"
 
@@ -18,7 +19,7 @@ func (p *AWSProvider) KeepInputArtifact() bool {
 	return true
 }
 
-func (p *AWSProvider) Process(ui packersdk.Ui, artifact packersdk.Artifact, dir string) (vagrantfile string, metadata map[string]interface{}, err error) {
+func (p *AWSProvider) Process(ui packersdk.Ui, artifact packersdk.Artifact, dir string) (vagrantfile string, metadata map[string]interface{}, files []BoxFile, err error) {
 	// Create the metadata
 	metadata = map[string]interface{}{"provider": "aws"}
 
@@ -27,16 +28,52 @@ func (p *AWSProvider) Process(ui packersdk.Ui, artifact packersdk.Artifact, dir
 		Images: make(map[string]string),
 	}
 
-	for _, regions := range strings.Split(artifact.Id(), ",") {
-		parts := strings.Split(regions, ":")
-		if len(parts) != 2 {
-			err = fmt.Errorf("Poorly formatted artifact ID: %s", artifact.Id())
-			return
+	// The amazon builders tell the AMIs by region in their state, other
+	// artifacts only in their ID
+	switch images := artifact.State("atlas.artifact.metadata").(type) {
+	case map[string]string:
+		for region, ami := range images {
+			tplData.Images[region] = ami
 		}
+	case map[string]interface{}:
+		for region, ami := range images {
+			if ami, ok := ami.(string); ok {
+				tplData.Images[region] = ami
+			}
+		}
+	}
+	if len(tplData.Images) == 0 {
+		for _, regions := range strings.Split(artifact.Id(), ",") {
+			parts := strings.Split(regions, ":")
+			if len(parts) != 2 {
+				err = fmt.Errorf("Poorly formatted artifact ID: %s", artifact.Id())
+				return
+			}
 
-		tplData.Images[parts[0]] = parts[1]
+			tplData.Images[parts[0]] = parts[1]
+		}
 	}
 
+	// The region the AMI was built in is the default one, or the first one
+	// when it isn't known
+	tplData.Region = generatedString(artifact, "BuildRegion")
+	if _, ok := tplData.Images[tplData.Region]; !ok {
+		regions := make([]string, 0, len(tplData.Images))
+		for region := range tplData.Images {
+			regions = append(regions, region)
+		}
+		sort.Strings(regions)
+		tplData.Region = regions[0]
+	}
+
+	sourceName := generatedString(artifact, "SourceAMIName")
+	tplData.InstanceType = awsInstanceTypes[awsArchitecture(sourceName)]
+	tplData.Windows = strings.Contains(strings.ToLower(sourceName), "windows")
+	if !tplData.Windows {
+		tplData.Username = awsUsername(sourceName)
+	}
+	metadata["region"] = tplData.Region
+
 	// Build up the contents
 	var contents bytes.Buffer
 	t := template.Must(template.New("vf").Parse(defaultAWSVagrantfile))
@@ -45,16 +82,110 @@ func (p *AWSProvider) Process(ui packersdk.Ui, artifact packersdk.Artifact, dir
 	return
 }
 
+// DetectArchitecture tells the architecture of the AMI from the name of the
+// AMI it was built from, as the amazon builders set it in their generated
+// data.
+func (p *AWSProvider) DetectArchitecture(artifact packersdk.Artifact) (string, error) {
+	return awsArchitecture(generatedString(artifact, "SourceAMIName")), nil
+}
+
+// generatedString returns a string of the data generated by the builder of
+// the artifact, or an empty string when it is not set.
+func generatedString(artifact packersdk.Artifact, key string) string {
+	s, _ := generatedValue(artifact, key).(string)
+	return s
+}
+
+// generatedValue returns a value of the data generated by the builder of the
+// artifact, or nil when it is not set.
+func generatedValue(artifact packersdk.Artifact, key string) interface{} {
+	switch data := artifact.State("generated_data").(type) {
+	case map[interface{}]interface{}:
+		return data[key]
+	case map[string]interface{}:
+		return data[key]
+	}
+	return nil
+}
+
+// awsArchitecture returns the architecture of an AMI from its name, like
+// ubuntu/images/hvm-ssd/ubuntu-jammy-22.04-arm64-server-20230516.
+func awsArchitecture(name string) string {
+	name = strings.ToLower(name)
+	switch {
+	case name == "":
+		return ""
+	case strings.Contains(name, "arm64"), strings.Contains(name, "aarch64"):
+		return "arm64"
+	case strings.Contains(name, "amd64"), strings.Contains(name, "x86_64"), strings.Contains(name, "x86-64"):
+		return "amd64"
+	}
+	return ""
+}
+
+// awsInstanceTypes are the instance types of the boxes by architecture, the
+// smallest general purpose ones able to run the AMI.
+var awsInstanceTypes = map[string]string{
+	"amd64": "t3.micro",
+	"arm64": "t4g.micro",
+}
+
+// awsUsernames are the default users of the AMIs of Linux distributions, by
+// a part of their name.
+var awsUsernames = []struct {
+	family   string
+	username string
+}{
+	{"ubuntu", "ubuntu"},
+	{"debian", "admin"},
+	{"centos", "centos"},
+	{"fedora", "fedora"},
+	{"rocky", "rocky"},
+	{"bitnami", "bitnami"},
+	{"amzn", "ec2-user"},
+	{"al2023", "ec2-user"},
+	{"rhel", "ec2-user"},
+	{"almalinux", "ec2-user"},
+	{"suse", "ec2-user"},
+	{"sles", "ec2-user"},
+}
+
+// awsUsername returns the user to connect to an AMI as, from the name of the
+// AMI it was built from.
+func awsUsername(name string) string {
+	name = strings.ToLower(name)
+	for _, family := range awsUsernames {
+		if strings.Contains(name, family.family) {
+			return family.username
+		}
+	}
+	return ""
+}
+
 type awsVagrantfileTemplate struct {
-	Images map[string]string
+	Images       map[string]string
+	Region       string
+	InstanceType string
+	Username     string
+	Windows      bool
 }
 
 var defaultAWSVagrantfile = `
 Vagrant.configure("2") do |config|
-  config.vm.provider "aws" do |aws|
-    {{ range $region, $ami := .Images }}
-	aws.region_config "{{ $region }}", ami: "{{ $ami }}"
-	{{ end }}
+  config.vm.provider "aws" do |aws, override|
+    aws.region = "{{ .Region }}"
+    {{- if .InstanceType }}
+    aws.instance_type = "{{ .InstanceType }}"
+    {{- end }}
+    {{- range $region, $ami := .Images }}
+    aws.region_config "{{ $region }}", ami: "{{ $ami }}"
+    {{- end }}
+    {{- if .Username }}
+    override.ssh.username = "{{ .Username }}"
+    {{- end }}
+    {{- if .Windows }}
+    override.vm.communicator = "winrm"
+    {{- end }}
   end
 end
 `
@@ -29,7 +29,7 @@ func TestAWSProvider_ArtifactId(t *testing.T) {
 		IdValue: "us-east-1:ami-1234",
 	}
 
-	vagrantfile, _, err := p.Process(ui, artifact, "foo")
+	vagrantfile, _, _, err := p.Process(ui, artifact, "foo")
 	if err != nil {
 		t.Fatalf("should not have error: %s", err)
 	}
@@ -38,3 +38,83 @@ func TestAWSProvider_ArtifactId(t *testing.T) {
 		t.Fatalf("wrong substitution: %s", vagrantfile)
 	}
 }
+
+func TestAWSProvider_GeneratedData(t *testing.T) {
+	p := new(AWSProvider)
+	ui := testUi()
+	artifact := &packersdk.MockArtifact{
+		IdValue: "eu-west-1:ami-5678,us-east-1:ami-1234",
+		StateValues: map[string]interface{}{
+			"atlas.artifact.metadata": map[string]string{
+				"us-east-1": "ami-1234",
+				"eu-west-1": "ami-5678",
+			},
+			"generated_data": map[interface{}]interface{}{
+				"BuildRegion":   "us-east-1",
+				"SourceAMIName": "ubuntu/images/hvm-ssd/ubuntu-jammy-22.04-arm64-server-20230516",
+			},
+		},
+	}
+
+	vagrantfile, metadata, _, err := p.Process(ui, artifact, "foo")
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	for _, result := range []string{
+		`config.vm.provider "aws" do |aws, override|`,
+		`aws.region = "us-east-1"`,
+		`aws.instance_type = "t4g.micro"`,
+		`aws.region_config "eu-west-1", ami: "ami-5678"`,
+		`aws.region_config "us-east-1", ami: "ami-1234"`,
+		`override.ssh.username = "ubuntu"`,
+	} {
+		if !strings.Contains(vagrantfile, result) {
+			t.Fatalf("missing %s: %s", result, vagrantfile)
+		}
+	}
+	if metadata["region"] != "us-east-1" {
+		t.Fatalf("bad metadata: %#v", metadata)
+	}
+
+	arch, err := p.DetectArchitecture(artifact)
+	if err != nil || arch != "arm64" {
+		t.Fatalf("bad architecture %q: %v", arch, err)
+	}
+}
+
+func TestAWSProvider_Windows(t *testing.T) {
+	artifact := &packersdk.MockArtifact{
+		IdValue: "us-west-2:ami-1234",
+		StateValues: map[string]interface{}{
+			"generated_data": map[string]interface{}{
+				"SourceAMIName": "Windows_Server-2022-English-Full-Base-2023.05.10",
+			},
+		},
+	}
+
+	vagrantfile, _, _, err := new(AWSProvider).Process(testUi(), artifact, "foo")
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if !strings.Contains(vagrantfile, `override.vm.communicator = "winrm"`) ||
+		!strings.Contains(vagrantfile, `aws.region = "us-west-2"`) ||
+		strings.Contains(vagrantfile, "ssh.username") {
+		t.Fatalf("bad Vagrantfile: %s", vagrantfile)
+	}
+}
+
+func TestAWSUsername(t *testing.T) {
+	cases := map[string]string{
+		"al2023-ami-2023.0.20230517.1-kernel-6.1-x86_64":             "ec2-user",
+		"amzn2-ami-kernel-5.10-hvm-2.0.20230515.0-x86_64-gp2":        "ec2-user",
+		"debian-12-arm64-20230612-1409":                              "admin",
+		"RHEL-9.2.0_HVM-20230503-x86_64-41-Hourly2-GP2":              "ec2-user",
+		"ubuntu/images/hvm-ssd/ubuntu-focal-20.04-amd64-server-2023": "ubuntu",
+		"my-own-image": "",
+	}
+	for name, expected := range cases {
+		if username := awsUsername(name); username != expected {
+			t.Errorf("%s: expected %q, got %q", name, expected, username)
+		}
+	}
+}
@@ -16,55 +16,102 @@ func (p *AzureProvider) KeepInputArtifact() bool {
 	return true
 }
 
-func (p *AzureProvider) Process(ui packersdk.Ui, artifact packersdk.Artifact, dir string) (vagrantfile string, metadata map[string]interface{}, err error) {
+func (p *AzureProvider) Process(ui packersdk.Ui, artifact packersdk.Artifact, dir string) (vagrantfile string, metadata map[string]interface{}, files []BoxFile, err error) {
 	// Create the metadata
 	metadata = map[string]interface{}{"provider": "azure"}
 
-	var AzureImageProps map[string]string
-	AzureImageProps = make(map[string]string)
+	props := azureImageProps(artifact)
+	ui.Message(fmt.Sprintf("Azure image: %+v", props))
 
-	// HACK(double16): It appears we can not access the Azure Artifact directly, so parse String()
-	artifactString := artifact.String()
-	ui.Message(fmt.Sprintf("artifact string: '%s'", artifactString))
-	lines := strings.Split(artifactString, "\n")
-	for l := 0; l < len(lines); l++ {
-		split := strings.Split(lines[l], ": ")
-		if len(split) > 1 {
-			AzureImageProps[strings.TrimSpace(split[0])] = strings.TrimSpace(split[1])
+	var settings []string
+	var location string
+	switch {
+	case props["ManagedImageSharedImageGalleryId"] != "":
+		// vagrant-azure creates VMs from a gallery image version as it does
+		// from a managed image, by ID
+		location = props["ManagedImageLocation"]
+		if location == "" {
+			location, _, _ = strings.Cut(props["SharedImageGalleryReplicatedRegions"], ",")
 		}
+		settings = append(settings, fmt.Sprintf("azure.vm_managed_image_id = %q", props["ManagedImageSharedImageGalleryId"]))
+		metadata["image_id"] = props["ManagedImageSharedImageGalleryId"]
+		metadata["resource_group"] = props["SharedImageGalleryResourceGroup"]
+	case props["ManagedImageId"] != "":
+		location = props["ManagedImageLocation"]
+		settings = append(settings, fmt.Sprintf("azure.vm_managed_image_id = %q", props["ManagedImageId"]))
+		metadata["image_id"] = props["ManagedImageId"]
+		metadata["resource_group"] = props["ManagedImageResourceGroupName"]
+	case props["OSDiskUri"] != "":
+		location = props["StorageAccountLocation"]
+		settings = append(settings, fmt.Sprintf("azure.vm_vhd_uri = %q", props["OSDiskUri"]))
+	default:
+		err = fmt.Errorf("No managed image, gallery image nor VHD URI found in artifact: %s", artifact.String())
+		return
+	}
+	if location = strings.TrimSpace(location); location != "" {
+		settings = append([]string{fmt.Sprintf("azure.location = %q", location)}, settings...)
 	}
-	ui.Message(fmt.Sprintf("artifact string parsed: %+v", AzureImageProps))
 
-	if AzureImageProps["ManagedImageId"] != "" {
-		vagrantfile = fmt.Sprintf(managedImageVagrantfile, AzureImageProps["ManagedImageLocation"], AzureImageProps["ManagedImageId"])
-	} else if AzureImageProps["OSDiskUri"] != "" {
-		vagrantfile = fmt.Sprintf(vhdVagrantfile, AzureImageProps["StorageAccountLocation"], AzureImageProps["OSDiskUri"], AzureImageProps["OSType"])
+	if group, _ := metadata["resource_group"].(string); group != "" {
+		settings = append(settings, fmt.Sprintf("azure.resource_group_name = %q", group))
 	} else {
-		err = fmt.Errorf("No managed image nor VHD URI found in artifact: %s", artifactString)
-		return
+		delete(metadata, "resource_group")
+	}
+
+	osType := props["OSType"]
+	if osType != "" {
+		settings = append(settings, fmt.Sprintf("azure.vm_operating_system = %q", osType))
+		metadata["os_type"] = osType
+	}
+	// Windows images are reached with WinRM, the others with SSH as Vagrant
+	// does by default
+	if strings.EqualFold(osType, "Windows") {
+		settings = append(settings, "override.winrm.transport = :ssl", "override.winrm.port = 5986")
 	}
+
+	vagrantfile = fmt.Sprintf(azureVagrantfile, strings.Join(settings, "\n\t\t"))
 	return
 }
 
-var managedImageVagrantfile = `
-Vagrant.configure("2") do |config|
-	config.vm.provider :azure do |azure, override|
-		azure.location = "%s"
-		azure.vm_managed_image_id = "%s"
-		override.winrm.transport = :ssl
-		override.winrm.port = 5986
-	end
-end
-`
+// azureImageProps returns the properties of the image the Azure builder made.
+// The builder only sets those of a VHD in the state of its artifact, the
+// other ones are read from the description of the artifact, one
+// "Key: value" per line.
+func azureImageProps(artifact packersdk.Artifact) map[string]string {
+	props := make(map[string]string)
+	for _, line := range strings.Split(artifact.String(), "\n") {
+		if key, value, ok := strings.Cut(line, ": "); ok {
+			props[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+
+	switch state := artifact.State("atlas.artifact.metadata").(type) {
+	case map[string]string:
+		for key, value := range state {
+			if value != "" {
+				props[key] = value
+			}
+		}
+	case map[string]interface{}:
+		for key, value := range state {
+			if value, ok := value.(string); ok && value != "" {
+				props[key] = value
+			}
+		}
+	}
+
+	// The ID of the artifact is the one of the gallery image version when
+	// the image is published to a gallery
+	if id := artifact.Id(); props["ManagedImageSharedImageGalleryId"] == "" && strings.Contains(strings.ToLower(id), "/galleries/") {
+		props["ManagedImageSharedImageGalleryId"] = id
+	}
+	return props
+}
 
-var vhdVagrantfile = `
+var azureVagrantfile = `
 Vagrant.configure("2") do |config|
 	config.vm.provider :azure do |azure, override|
-		azure.location = "%s"
-		azure.vm_vhd_uri = "%s"
-		azure.vm_operating_system = "%s"
-		override.winrm.transport = :ssl
-		override.winrm.port = 5986
+		%s
 	end
 end
 `
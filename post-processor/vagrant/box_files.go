@@ -0,0 +1,207 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package vagrant
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// defaultExcludes are the files each provider leaves out of the box, on top
+// of the ones excluded in the configuration, as they are unnecessary for the
+// function of the virtual machine.
+var defaultExcludes = map[string][]string{
+	"parallels": {"*.log", "*.backup", "*.Backup", "*.app", "Windows Disks"},
+	"vmware":    {"*.log", "*.lck", "*.scoreboard", "*~"},
+}
+
+// excludeRegexp returns a regular expression matching the same paths as the
+// exclude pattern does.
+func excludeRegexp(pattern string) string {
+	var re strings.Builder
+	re.WriteString("(^|/)")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			re.WriteString("[^/]*")
+		case '?':
+			re.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(pattern[i:], ']')
+			if end < 0 {
+				re.WriteString(regexp.QuoteMeta(pattern[i:]))
+				i = len(pattern)
+				continue
+			}
+			re.WriteString(pattern[i : i+end+1])
+			i += end
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+			}
+			re.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		default:
+			re.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	if strings.Contains(pattern, "/") {
+		re.WriteString("$")
+	} else {
+		re.WriteString("(/|$)")
+	}
+	return re.String()
+}
+
+// excluded tells whether a file matches any of the patterns. Patterns
+// without a slash are matched against the name of the file and of each of
+// its directories, the others against the end of its path.
+func excluded(patterns []string, name string) bool {
+	parts := strings.Split(filepath.ToSlash(name), "/")
+	for _, pattern := range patterns {
+		for i := range parts {
+			subject := parts[i]
+			if strings.Contains(pattern, "/") {
+				subject = strings.Join(parts[i:], "/")
+			}
+			if matched, _ := path.Match(pattern, subject); matched {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func filterBoxFiles(patterns []string, files []BoxFile) []BoxFile {
+	filtered := files[:0]
+	for _, f := range files {
+		if !excluded(patterns, f.Name) {
+			filtered = append(filtered, f)
+		}
+	}
+	return filtered
+}
+
+// filteredArtifact is an artifact without its excluded files.
+type filteredArtifact struct {
+	packersdk.Artifact
+	files []string
+}
+
+// newFilteredArtifact leaves out the files of the artifact matching the
+// patterns. Files are matched by their path in the output directory of the
+// artifact, so that the patterns don't match the directories it is in.
+func newFilteredArtifact(artifact packersdk.Artifact, patterns []string) *filteredArtifact {
+	filtered := &filteredArtifact{Artifact: artifact}
+	paths := artifact.Files()
+	outputDir := commonDir(paths)
+	for _, f := range paths {
+		name, err := filepath.Rel(outputDir, f)
+		if err != nil {
+			name = filepath.Base(f)
+		}
+		if !excluded(patterns, name) {
+			filtered.files = append(filtered.files, f)
+		}
+	}
+	return filtered
+}
+
+// commonDir returns the deepest directory all of the paths are in.
+func commonDir(paths []string) string {
+	if len(paths) == 0 {
+		return ""
+	}
+	dir := filepath.Dir(paths[0])
+	for _, path := range paths[1:] {
+		for !isWithin(dir, path) {
+			parent := filepath.Dir(dir)
+			if parent == dir {
+				break
+			}
+			dir = parent
+		}
+	}
+	return dir
+}
+
+// isWithin tells whether path is inside the directory dir.
+func isWithin(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func (a *filteredArtifact) Files() []string {
+	return a.files
+}
+
+// parseInclude splits an include into the file or directory to include and
+// where it goes in the box, which follows the last colon. Includes naming an
+// existing path are left whole, so that paths with colons don't need a
+// destination.
+func parseInclude(include string) (string, string) {
+	i := strings.LastIndex(include, ":")
+	// Leave drive letters of Windows paths alone
+	isDrive := i == 1 && ('a' <= include[0]|0x20 && include[0]|0x20 <= 'z')
+	if i < 0 || isDrive || i == len(include)-1 {
+		return include, ""
+	}
+	if _, err := os.Stat(include); err == nil {
+		return include, ""
+	}
+	return include[:i], include[i+1:]
+}
+
+// includeFiles returns the files to add to the box for an include. Files
+// go to the root of the box, and directories keep their structure, unless a
+// destination is given. Excluded files are left out, whether they are named
+// by the include or in one of its directories.
+func includeFiles(include string, patterns []string) ([]BoxFile, error) {
+	src, dst := parseInclude(include)
+	if dst != "" {
+		var err error
+		if dst, err = archivePath(dst); err != nil {
+			return nil, fmt.Errorf("Invalid destination for include %s: %s", src, err)
+		}
+	}
+
+	info, err := os.Stat(src)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading include file: %s\n\n%s", src, err)
+	}
+	if dst == "" {
+		dst = filepath.Base(src)
+	}
+	if !info.IsDir() {
+		if excluded(patterns, dst) {
+			return nil, nil
+		}
+		return []BoxFile{{Name: dst, Path: src}}, nil
+	}
+
+	var files []BoxFile
+	err = filepath.Walk(src, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		name := path.Join(dst, filepath.ToSlash(rel))
+		if !excluded(patterns, name) {
+			files = append(files, BoxFile{Name: name, Path: p})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Error reading include directory: %s\n\n%s", src, err)
+	}
+	return files, nil
+}
@@ -0,0 +1,230 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package vagrant
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"testing"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func TestExcluded(t *testing.T) {
+	cases := []struct {
+		name     string
+		patterns []string
+		excluded bool
+	}{
+		{"/output/packer.vmx", defaultExcludes["vmware"], false},
+		{"/output/vmware.log", defaultExcludes["vmware"], true},
+		{"/output/packer.vmx.lck/M12345.lck", defaultExcludes["vmware"], true},
+		{"/output/packer.scoreboard", defaultExcludes["vmware"], true},
+		{"/output/packer.nvram~", defaultExcludes["vmware"], true},
+		{"/output/packer.pvm/config.pvs", defaultExcludes["parallels"], false},
+		{"/output/packer.pvm/config.pvs.backup", defaultExcludes["parallels"], true},
+		{"/output/packer.pvm/Windows Disks/C", defaultExcludes["parallels"], true},
+		{"/output/packer.pvm/Tools.app/Contents/Info.plist", defaultExcludes["parallels"], true},
+		{"/output/packer.nvram", []string{"*.nvram"}, true},
+		{"/output/caches/disk", []string{"caches"}, true},
+		{"/output/logs/boot.txt", []string{"logs/*.txt"}, true},
+		{"/output/boot.txt", []string{"logs/*.txt"}, false},
+		{"/output/logs/boot.txt", []string{"output/logs/*"}, true},
+		{"/output/logs/boot.txt", nil, false},
+	}
+	for _, tc := range cases {
+		if excluded(tc.patterns, tc.name) != tc.excluded {
+			t.Errorf("%s should be excluded by %v: %t", tc.name, tc.patterns, tc.excluded)
+		}
+	}
+}
+
+func TestExcludeRegexp(t *testing.T) {
+	patterns := []string{"*.log", "caches", "logs/*.txt", "disk-?.vmdk", "[ab].img", `\*`}
+	names := []string{
+		"vm.log", "vm.log.1", "logs/vm.log", "caches/index", "my-caches/index",
+		"logs/a.txt", "vm/logs/a.txt", "a.txt", "disk-1.vmdk", "disk-10.vmdk",
+		"a.img", "c.img", "*", "x",
+	}
+	for _, pattern := range patterns {
+		re := regexp.MustCompile(excludeRegexp(pattern))
+		for _, name := range names {
+			if expected := excluded([]string{pattern}, name); re.MatchString(name) != expected {
+				t.Errorf("%s (%s) on %s: expected a match: %t", pattern, re, name, expected)
+			}
+		}
+	}
+}
+
+func TestParseInclude(t *testing.T) {
+	cases := []struct {
+		include, src, dst string
+	}{
+		{"setup.sh", "setup.sh", ""},
+		{"scripts/setup.sh:provision/setup.sh", "scripts/setup.sh", "provision/setup.sh"},
+		{`C:\scripts\setup.sh`, `C:\scripts\setup.sh`, ""},
+		{`C:\scripts\setup.sh:setup.sh`, `C:\scripts\setup.sh`, "setup.sh"},
+	}
+	// Paths with colons are left whole when they exist
+	dir := t.TempDir()
+	colon := filepath.Join(dir, "backup:2024")
+	if err := os.WriteFile(colon, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	cases = append(cases, []struct {
+		include, src, dst string
+	}{
+		{colon, colon, ""},
+		{colon + ":backup", colon, "backup"},
+		{"setup.sh:", "setup.sh:", ""},
+	}...)
+
+	for _, tc := range cases {
+		src, dst := parseInclude(tc.include)
+		if src != tc.src || dst != tc.dst {
+			t.Errorf("%s: expected %q and %q, got %q and %q", tc.include, tc.src, tc.dst, src, dst)
+		}
+	}
+}
+
+func TestNewFilteredArtifact(t *testing.T) {
+	// Patterns don't match the directories the output directory is in
+	artifact := &packersdk.MockArtifact{FilesValue: []string{
+		"/build/output-vmware/tmp/packer.vmx",
+		"/build/output-vmware/tmp/packer.vmdk",
+		"/build/output-vmware/tmp/vmware.log",
+	}}
+	filtered := newFilteredArtifact(artifact, []string{"*.log", "build", "output*", "tmp"})
+	expected := []string{"/build/output-vmware/tmp/packer.vmx", "/build/output-vmware/tmp/packer.vmdk"}
+	if !reflect.DeepEqual(filtered.Files(), expected) {
+		t.Errorf("expected %v, got %v", expected, filtered.Files())
+	}
+
+	artifact = &packersdk.MockArtifact{FilesValue: []string{
+		"/Users/me/VMs.app/packer.pvm",
+		"/Users/me/VMs.app/packer.pvm/config.pvs",
+		"/Users/me/VMs.app/packer.pvm/Tools.app/Info.plist",
+	}}
+	filtered = newFilteredArtifact(artifact, defaultExcludes["parallels"])
+	expected = []string{"/Users/me/VMs.app/packer.pvm", "/Users/me/VMs.app/packer.pvm/config.pvs"}
+	if !reflect.DeepEqual(filtered.Files(), expected) {
+		t.Errorf("expected %v, got %v", expected, filtered.Files())
+	}
+}
+
+func TestIncludeFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"setup.sh", "files/a.txt", "files/sub/b.txt", "files/debug.log"} {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := []struct {
+		include string
+		names   []string
+	}{
+		{filepath.Join(dir, "setup.sh"), []string{"setup.sh"}},
+		{filepath.Join(dir, "setup.sh") + ":provision/setup.sh", []string{"provision/setup.sh"}},
+		{filepath.Join(dir, "files"), []string{"files/a.txt", "files/sub/b.txt"}},
+		{filepath.Join(dir, "files") + ":provision", []string{"provision/a.txt", "provision/sub/b.txt"}},
+		{filepath.Join(dir, "files") + ":.", []string{"a.txt", "sub/b.txt"}},
+		{filepath.Join(dir, "files", "debug.log"), nil},
+		{filepath.Join(dir, "setup.sh") + ":logs/setup.log", nil},
+	}
+	for _, tc := range cases {
+		files, err := includeFiles(tc.include, []string{"*.log"})
+		if err != nil {
+			t.Fatalf("%s: %s", tc.include, err)
+		}
+		var names []string
+		for _, f := range files {
+			names = append(names, f.Name)
+		}
+		sort.Strings(names)
+		if !reflect.DeepEqual(names, tc.names) {
+			t.Errorf("%s: expected %v, got %v", tc.include, tc.names, names)
+		}
+	}
+
+	if _, err := includeFiles(filepath.Join(dir, "setup.sh")+":../setup.sh", nil); err == nil {
+		t.Fatal("a destination out of the box should be an error")
+	}
+	if _, err := includeFiles(filepath.Join(dir, "missing"), nil); err == nil {
+		t.Fatal("a missing include should be an error")
+	}
+}
+
+func TestPostProcessorPostProcess_exclude(t *testing.T) {
+	dir := t.TempDir()
+	var inputs []string
+	for _, name := range []string{"disk.img", "disk.nvram", "build.log"} {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+		inputs = append(inputs, p)
+	}
+	scripts := filepath.Join(dir, "scripts")
+	if err := os.Mkdir(scripts, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"setup.sh", "setup.log"} {
+		if err := os.WriteFile(filepath.Join(scripts, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var p PostProcessor
+	err := p.Configure(map[string]interface{}{
+		"exclude": []string{"*.nvram", "*.log"},
+		"include": []string{scripts + ":provision"},
+		"format":  FormatTar,
+		"output":  filepath.Join(dir, "package.box"),
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	artifact := &packersdk.MockArtifact{
+		BuilderIdValue: "packer.file",
+		IdValue:        "file",
+		FilesValue:     inputs,
+	}
+	result, _, _, err := p.PostProcess(context.Background(), testUi(), artifact)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer result.Destroy()
+
+	var names []string
+	err = walkBox(filepath.Join(dir, "package.box"), FormatTar, defaultExtractLimits, func(name string, _ io.Reader) error {
+		names = append(names, name)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(names)
+	expected := []string{"Vagrantfile", "disk.img", "metadata.json", "provision/setup.sh"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("expected %v, got %v", expected, names)
+	}
+}
+
+func TestPostProcessorPrepare_exclude(t *testing.T) {
+	var p PostProcessor
+	if err := p.Configure(map[string]interface{}{"exclude": []string{"[*.log"}}); err == nil {
+		t.Fatal("an invalid pattern should be an error")
+	}
+}
@@ -0,0 +1,277 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package vagrant
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/flate"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/klauspost/pgzip"
+	"github.com/ulikunitz/xz"
+)
+
+// Archive formats a box can be written in. These are all understood by the
+// box extractor of Vagrant.
+const (
+	FormatTar    = "tar"
+	FormatTarGz  = "tar.gz"
+	FormatTarXz  = "tar.xz"
+	FormatTarZst = "tar.zst"
+	FormatZip    = "zip"
+)
+
+var boxFormats = []string{FormatTar, FormatTarGz, FormatTarXz, FormatTarZst, FormatZip}
+
+// Dictionary sizes matching the presets of the xz command line tool, indexed
+// by compression level. The xz writer has no other setting, so the level
+// only changes the dictionary size, and 0 still compresses.
+var xzDictCaps = []int{
+	256 << 10, 1 << 20, 2 << 20, 4 << 20, 4 << 20,
+	8 << 20, 8 << 20, 16 << 20, 32 << 20, 64 << 20,
+}
+
+// BoxFormat returns the archive format to write a box in. Without an explicit
+// format, a compression level of 0 means a plain tar and anything else a
+// gzipped tar, which is what the post-processor always did.
+func BoxFormat(format string, level int) string {
+	if format != "" {
+		return format
+	}
+	if level == flate.NoCompression {
+		return FormatTar
+	}
+	return FormatTarGz
+}
+
+// ValidateCompression checks that the format is known and that the
+// compression level makes sense for it.
+func ValidateCompression(format string, level int) error {
+	switch BoxFormat(format, level) {
+	case FormatTar:
+		// Nothing gets compressed, so any level will do
+		return nil
+	case FormatTarGz, FormatTarXz, FormatZip:
+		if level < flate.DefaultCompression || level > flate.BestCompression {
+			return fmt.Errorf("Invalid compression level %d for format %s. "+
+				"Expected an integer from -1 to 9.", level, BoxFormat(format, level))
+		}
+	case FormatTarZst:
+		// zstd always compresses, 0 selects its fastest level as 1 does
+		if level < flate.DefaultCompression || level > 22 {
+			return fmt.Errorf("Invalid compression level %d for format %s. "+
+				"Expected an integer from -1 to 22.", level, format)
+		}
+	default:
+		return fmt.Errorf("Invalid format %q. Expected one of %s.",
+			format, strings.Join(boxFormats, ", "))
+	}
+	return nil
+}
+
+// BoxOptions controls how a box is written.
+type BoxOptions struct {
+	// Format of the archive, see BoxFormat.
+	Format string
+	// CompressionLevel of the archive, checked with ValidateCompression.
+	CompressionLevel int
+	// Reproducible makes the box only depend on the contents of its files:
+	// entries are sorted by name, all get ModTime as their modification
+	// time, no owner, and either 0644 or 0755 as permissions.
+	Reproducible bool
+	ModTime      time.Time
+}
+
+// SourceDateEpoch returns the time set in the SOURCE_DATE_EPOCH environment
+// variable, used as the modification time of the files of reproducible boxes.
+// It defaults to the Unix epoch.
+func SourceDateEpoch() (time.Time, error) {
+	epoch := os.Getenv("SOURCE_DATE_EPOCH")
+	if epoch == "" {
+		return time.Unix(0, 0).UTC(), nil
+	}
+	seconds, err := strconv.ParseInt(epoch, 10, 64)
+	if err != nil || seconds < 0 {
+		return time.Time{}, fmt.Errorf("Invalid SOURCE_DATE_EPOCH %q. Expected a number of seconds since the Unix epoch.", epoch)
+	}
+	return time.Unix(seconds, 0).UTC(), nil
+}
+
+// normalizeMode keeps only whether a file is executable, so that the box
+// doesn't depend on the umask of the host it was built on.
+func normalizeMode(mode os.FileMode) os.FileMode {
+	if mode&0111 != 0 {
+		return 0755
+	}
+	return 0644
+}
+
+// boxArchive is the archive a box is written to.
+type boxArchive interface {
+	// Add writes a file to the archive, under the given relative name.
+	Add(name string, info os.FileInfo, r io.Reader) error
+	// Close flushes the archive, and any compression around it.
+	Close() error
+}
+
+// newBoxArchive returns an archive writing to w in the format of the options,
+// which must have been resolved with BoxFormat. The level must have been
+// checked with ValidateCompression. Progress may be nil.
+func newBoxArchive(w io.Writer, opts BoxOptions, progress *boxProgress) (boxArchive, error) {
+	level := opts.CompressionLevel
+	if opts.Format == FormatZip {
+		zipWriter := zip.NewWriter(w)
+		zipWriter.RegisterCompressor(zip.Deflate, func(out io.Writer) (io.WriteCloser, error) {
+			return flate.NewWriter(out, level)
+		})
+		return &zipArchive{writer: zipWriter, opts: opts, progress: progress}, nil
+	}
+
+	var compressor io.WriteCloser
+	switch opts.Format {
+	case FormatTarGz:
+		gzipWriter, err := makePgzipWriter(w, level)
+		if err != nil {
+			return nil, err
+		}
+		if opts.Reproducible {
+			// Leave the name, time and OS of the host out of the header
+			gzipWriter.Header = pgzip.Header{OS: 255}
+		}
+		compressor = gzipWriter
+	case FormatTarXz:
+		if level == flate.DefaultCompression {
+			level = 6
+		}
+		xzWriter, err := xz.WriterConfig{DictCap: xzDictCaps[level]}.NewWriter(w)
+		if err != nil {
+			return nil, err
+		}
+		compressor = xzWriter
+	case FormatTarZst:
+		encoderLevel := zstd.SpeedDefault
+		if level != flate.DefaultCompression {
+			encoderLevel = zstd.EncoderLevelFromZstd(level)
+		}
+		zstdWriter, err := zstd.NewWriter(w,
+			zstd.WithEncoderLevel(encoderLevel),
+			zstd.WithEncoderConcurrency(runtime.GOMAXPROCS(-1)))
+		if err != nil {
+			return nil, err
+		}
+		compressor = zstdWriter
+	}
+
+	archive := &tarArchive{out: w, compressor: compressor, opts: opts, progress: progress}
+	if compressor != nil {
+		archive.out = compressor
+	}
+	archive.writer = tar.NewWriter(archive.out)
+	return archive, nil
+}
+
+type tarArchive struct {
+	writer *tar.Writer
+	// out is what the tar is written to, for the entries written without
+	// the tar writer
+	out        io.Writer
+	compressor io.WriteCloser
+	opts       BoxOptions
+	progress   *boxProgress
+}
+
+func (a *tarArchive) Add(name string, info os.FileInfo, r io.Reader) error {
+	header, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+
+	// go >=1.10 wants to use GNU tar format to workaround issues in
+	// libarchive < 3.3.2
+	setHeaderFormat(header)
+
+	// We have to set the Name explicitly because it is supposed to
+	// be a relative path to the root. Otherwise, the tar ends up
+	// being a bunch of files in the root, even if they're actually
+	// nested in a dir in the original "dir" param.
+	header.Name = name
+
+	if a.opts.Reproducible {
+		header.ModTime = a.opts.ModTime
+		header.AccessTime = time.Time{}
+		header.ChangeTime = time.Time{}
+		header.Uid, header.Gid = 0, 0
+		header.Uname, header.Gname = "", ""
+		header.Mode = int64(normalizeMode(info.Mode()))
+	}
+
+	// Disk images are mostly holes, which are left out of the box
+	if f, ok := r.(*os.File); ok && info.Mode().IsRegular() {
+		data, err := sparseData(f, info.Size())
+		if err != nil {
+			return err
+		}
+		if isSparse(data, info.Size()) {
+			return a.addSparse(header, f, data)
+		}
+	}
+
+	if err := a.writer.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err = io.Copy(a.writer, a.progress.reader(r))
+	return err
+}
+
+func (a *tarArchive) Close() error {
+	err := a.writer.Close()
+	if a.compressor != nil {
+		if cerr := a.compressor.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+type zipArchive struct {
+	writer   *zip.Writer
+	opts     BoxOptions
+	progress *boxProgress
+}
+
+func (a *zipArchive) Add(name string, info os.FileInfo, r io.Reader) error {
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+	header.Name = filepath.ToSlash(name)
+	header.Method = zip.Deflate
+	if a.opts.CompressionLevel == flate.NoCompression {
+		header.Method = zip.Store
+	}
+	if a.opts.Reproducible {
+		header.Modified = a.opts.ModTime
+		header.SetMode(normalizeMode(info.Mode()))
+	}
+
+	w, err := a.writer.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, a.progress.reader(r))
+	return err
+}
+
+func (a *zipArchive) Close() error {
+	return a.writer.Close()
+}
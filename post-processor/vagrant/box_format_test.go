@@ -0,0 +1,272 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package vagrant
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/flate"
+	"compress/gzip"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+func TestBoxFormat(t *testing.T) {
+	if f := BoxFormat("", flate.NoCompression); f != FormatTar {
+		t.Fatalf("expected an uncompressed box to be a tar, got %s", f)
+	}
+	if f := BoxFormat("", flate.DefaultCompression); f != FormatTarGz {
+		t.Fatalf("expected a compressed box to be a tar.gz, got %s", f)
+	}
+	if f := BoxFormat(FormatTarZst, flate.NoCompression); f != FormatTarZst {
+		t.Fatalf("expected the format to be kept, got %s", f)
+	}
+}
+
+func TestDirToBox_formats(t *testing.T) {
+	dir := t.TempDir()
+	if err := WriteMetadata(dir, map[string]string{"provider": "virtualbox"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, format := range boxFormats {
+		t.Run(format, func(t *testing.T) {
+			for _, level := range []int{flate.DefaultCompression, flate.NoCompression} {
+				box := filepath.Join(t.TempDir(), "package.box")
+				if _, _, err := DirToBox(box, dir, nil, nil, BoxOptions{Format: format, CompressionLevel: level}); err != nil {
+					t.Fatalf("level %d: %s", level, err)
+				}
+
+				names := boxEntries(t, box, format)
+				if len(names) != 1 || names[0] != "metadata.json" {
+					t.Fatalf("level %d: unexpected box contents: %v", level, names)
+				}
+			}
+		})
+	}
+}
+
+func boxEntries(t *testing.T, box, format string) []string {
+	var names []string
+	if format == FormatZip {
+		r, err := zip.OpenReader(box)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Close()
+		for _, f := range r.File {
+			names = append(names, f.Name)
+		}
+		return names
+	}
+
+	f, err := os.Open(box)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	switch format {
+	case FormatTarGz:
+		r, err = gzip.NewReader(f)
+	case FormatTarXz:
+		r, err = xz.NewReader(f)
+	case FormatTarZst:
+		var d *zstd.Decoder
+		d, err = zstd.NewReader(f)
+		if err == nil {
+			defer d.Close()
+		}
+		r = d
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, hdr.Name)
+	}
+	return names
+}
+
+func TestDirToBox_files(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "Vagrantfile"), []byte("generated"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "box.ovf"), []byte("replaced"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	src := filepath.Join(t.TempDir(), "packer.ovf")
+	if err := os.WriteFile(src, []byte("ovf"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	files := []BoxFile{
+		{Name: "box.ovf", Path: "/does/not/exist"},
+		{Name: "box.ovf", Path: src},
+		{
+			Name: "disks/disk.vmdk",
+			Size: 4,
+			Open: func() (io.ReadCloser, error) {
+				return io.NopCloser(strings.NewReader("disk")), nil
+			},
+		},
+	}
+	box := filepath.Join(t.TempDir(), "package.box")
+	digests, _, err := DirToBox(box, dir, files, nil, BoxOptions{Format: FormatTar, CompressionLevel: flate.NoCompression})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	raw, err := os.ReadFile(box)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum256, sum512 := sha256.Sum256(raw), sha512.Sum512(raw)
+	if digests["sha256"] != hex.EncodeToString(sum256[:]) || digests["sha512"] != hex.EncodeToString(sum512[:]) {
+		t.Fatalf("digests don't match the box: %v", digests)
+	}
+
+	f, err := os.Open(box)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	contents := map[string]string{}
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents[hdr.Name] = string(b)
+	}
+
+	expected := map[string]string{
+		"Vagrantfile":     "generated",
+		"box.ovf":         "ovf",
+		"disks/disk.vmdk": "disk",
+	}
+	if !reflect.DeepEqual(contents, expected) {
+		t.Fatalf("expected box contents %v, got %v", expected, contents)
+	}
+}
+
+func TestDirToBox_reproducible(t *testing.T) {
+	t.Setenv("SOURCE_DATE_EPOCH", "1700000000")
+	modTime, err := SourceDateEpoch()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Two copies of the same files, with different times, permissions and
+	// order
+	writeInputs := func(mtime time.Time, mode os.FileMode, reversed bool) (string, []BoxFile) {
+		dir := t.TempDir()
+		var files []BoxFile
+		for _, name := range []string{"a.img", "b.img", "c.img"} {
+			path := filepath.Join(dir, name)
+			if err := os.WriteFile(path, []byte(name), mode); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chmod(path, mode); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chtimes(path, mtime, mtime); err != nil {
+				t.Fatal(err)
+			}
+			files = append(files, BoxFile{Name: name, Path: path})
+		}
+		if reversed {
+			files[0], files[2] = files[2], files[0]
+		}
+		return dir, files
+	}
+	dir1, files1 := writeInputs(time.Unix(1, 0), 0600, false)
+	_, files2 := writeInputs(time.Now(), 0640, true)
+
+	for _, format := range boxFormats {
+		t.Run(format, func(t *testing.T) {
+			opts := BoxOptions{
+				Format:           format,
+				CompressionLevel: flate.DefaultCompression,
+				Reproducible:     true,
+				ModTime:          modTime,
+			}
+			box1 := filepath.Join(t.TempDir(), "package.box")
+			digests1, _, err := DirToBox(box1, t.TempDir(), files1, nil, opts)
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			box2 := filepath.Join(t.TempDir(), "package.box")
+			digests2, _, err := DirToBox(box2, t.TempDir(), files2, nil, opts)
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			if digests1["sha256"] != digests2["sha256"] {
+				t.Fatalf("boxes of identical files differ")
+			}
+
+			names := boxEntries(t, box1, format)
+			if !reflect.DeepEqual(names, []string{"a.img", "b.img", "c.img"}) {
+				t.Fatalf("entries should be sorted: %v", names)
+			}
+		})
+	}
+
+	box := filepath.Join(t.TempDir(), "package.box")
+	opts := BoxOptions{Format: FormatTar, Reproducible: true, ModTime: modTime}
+	if _, _, err := DirToBox(box, dir1, nil, nil, opts); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	f, err := os.Open(box)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !hdr.ModTime.Equal(modTime) || hdr.Uid != 0 || hdr.Gid != 0 || hdr.Uname != "" || hdr.Gname != "" {
+			t.Fatalf("host details leaked into %s: %+v", hdr.Name, hdr)
+		}
+		if hdr.Mode != 0644 {
+			t.Fatalf("expected mode 0644 for %s, got %o", hdr.Name, hdr.Mode)
+		}
+	}
+}
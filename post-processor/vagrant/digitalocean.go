@@ -23,7 +23,7 @@ func (p *DigitalOceanProvider) KeepInputArtifact() bool {
 	return true
 }
 
-func (p *DigitalOceanProvider) Process(ui packersdk.Ui, artifact packersdk.Artifact, dir string) (vagrantfile string, metadata map[string]interface{}, err error) {
+func (p *DigitalOceanProvider) Process(ui packersdk.Ui, artifact packersdk.Artifact, dir string) (vagrantfile string, metadata map[string]interface{}, files []BoxFile, err error) {
 	// Create the metadata
 	metadata = map[string]interface{}{"provider": "digital_ocean"}
 
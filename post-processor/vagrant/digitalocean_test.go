@@ -29,7 +29,7 @@ func TestDigitalOceanProvider_ArtifactId(t *testing.T) {
 		IdValue: "San Francisco:42",
 	}
 
-	vagrantfile, _, err := p.Process(ui, artifact, "foo")
+	vagrantfile, _, _, err := p.Process(ui, artifact, "foo")
 	if err != nil {
 		t.Fatalf("should not have error: %s", err)
 	}
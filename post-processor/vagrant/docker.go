@@ -5,28 +5,124 @@ package vagrant
 
 import (
 	"fmt"
+	"strings"
 
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 )
 
-type DockerProvider struct{}
+type DockerProvider struct {
+	// architecture of the box, which the image is run for
+	architecture string
+	// hasSSH tells whether the image runs an SSH server Vagrant connects to
+	hasSSH bool
+}
 
 func (p *DockerProvider) KeepInputArtifact() bool {
 	return false
 }
 
-func (p *DockerProvider) Process(ui packersdk.Ui, artifact packersdk.Artifact, dir string) (vagrantfile string, metadata map[string]interface{}, err error) {
+func (p *DockerProvider) SetArchitecture(arch string) {
+	p.architecture = arch
+}
+
+func (p *DockerProvider) SetHasSSH(hasSSH bool) {
+	p.hasSSH = hasSSH
+}
+
+func (p *DockerProvider) Process(ui packersdk.Ui, artifact packersdk.Artifact, dir string) (vagrantfile string, metadata map[string]interface{}, files []BoxFile, err error) {
 	// Create the metadata
 	metadata = map[string]interface{}{"provider": "docker"}
 
-	vagrantfile = fmt.Sprintf(dockerVagrantfile, artifact.Id())
+	image := dockerImage(ui, artifact)
+	metadata["image"] = image
+	settings := []string{fmt.Sprintf("docker.image = %q", image)}
+	if platform := dockerPlatforms[p.architecture]; platform != "" {
+		settings = append(settings, fmt.Sprintf("docker.create_args = [\"--platform\", %q]", platform))
+		metadata["platform"] = platform
+	}
+
+	// Images built by Packer usually don't run an SSH server, so Vagrant
+	// doesn't connect to the containers unless told otherwise
+	if p.hasSSH {
+		settings = append(settings, "docker.has_ssh = true")
+	} else {
+		settings = append(settings, "docker.has_ssh = false", `override.vm.communicator = "none"`)
+	}
+
+	vagrantfile = fmt.Sprintf(dockerVagrantfile, strings.Join(settings, "\n\t\t"))
 	return
 }
 
+// dockerImage returns the reference to the image of the artifact. The
+// digest set by docker-push pins the image in its repository, named by the
+// first tag set by docker-tag or docker-push, or by the ID of the artifact.
+// Otherwise, the first tag names the image, or else the ID of the artifact,
+// as it is an image ID or a reference already.
+func dockerImage(ui packersdk.Ui, artifact packersdk.Artifact) string {
+	var tags []string
+	switch state := artifact.State("docker_tags").(type) {
+	case []string:
+		tags = state
+	case []interface{}:
+		for _, tag := range state {
+			if tag, ok := tag.(string); ok {
+				tags = append(tags, tag)
+			}
+		}
+	}
+
+	repository := ""
+	if len(tags) > 0 {
+		repository = dockerRepository(tags[0])
+	} else if id := artifact.Id(); !strings.HasPrefix(id, "sha256:") {
+		repository = dockerRepository(id)
+	}
+	if digest, ok := artifact.State("digest").(string); ok && digest != "" {
+		switch {
+		case strings.Contains(digest, "@"):
+			return digest
+		case repository != "":
+			return repository + "@" + digest
+		}
+		ui.Message(fmt.Sprintf("Warning: the image can't be pinned to its digest %s, "+
+			"as its repository isn't known: using %s", digest, artifact.Id()))
+	}
+	if len(tags) > 0 {
+		return tags[0]
+	}
+	return artifact.Id()
+}
+
+// dockerRepository returns the repository of an image reference, without its
+// tag or digest.
+func dockerRepository(ref string) string {
+	if i := strings.Index(ref, "@"); i >= 0 {
+		ref = ref[:i]
+	}
+	// The tag follows the last colon, unless it is the one of the port of
+	// the registry
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		ref = ref[:i]
+	}
+	return ref
+}
+
+// dockerPlatforms are the platforms of Docker images by architecture of
+// Vagrant.
+var dockerPlatforms = map[string]string{
+	"amd64":   "linux/amd64",
+	"i386":    "linux/386",
+	"arm64":   "linux/arm64",
+	"arm":     "linux/arm/v7",
+	"ppc64le": "linux/ppc64le",
+	"s390x":   "linux/s390x",
+	"riscv64": "linux/riscv64",
+}
+
 var dockerVagrantfile = `
 Vagrant.configure("2") do |config|
 	config.vm.provider :docker do |docker, override|
-		docker.image = "%s"
+		%s
 	end
 end
 `
@@ -4,9 +4,86 @@
 package vagrant
 
 import (
+	"bytes"
+	"strings"
 	"testing"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 )
 
 func TestDockerProvider_impl(t *testing.T) {
 	var _ Provider = new(DockerProvider)
+	var _ SSHSetter = new(DockerProvider)
+}
+
+func TestDockerProvider_Process(t *testing.T) {
+	p := new(DockerProvider)
+	p.SetArchitecture("arm64")
+	artifact := &packersdk.MockArtifact{
+		BuilderIdValue: "packer.post-processor.docker-push",
+		IdValue:        "registry.example.com:5000/team/app:1.0",
+		StateValues: map[string]interface{}{
+			"docker_tags": []interface{}{"registry.example.com:5000/team/app:1.0"},
+			"digest":      "sha256:4b825dc642cb6eb9a060e54bf8d69288fbee4904b825dc642cb6eb9a060e54bf",
+		},
+	}
+
+	vagrantfile, metadata, _, err := p.Process(testUi(), artifact, "foo")
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	image := "registry.example.com:5000/team/app@sha256:4b825dc642cb6eb9a060e54bf8d69288fbee4904b825dc642cb6eb9a060e54bf"
+	for _, result := range []string{
+		`docker.image = "` + image + `"`,
+		`docker.create_args = ["--platform", "linux/arm64"]`,
+		`docker.has_ssh = false`,
+		`override.vm.communicator = "none"`,
+	} {
+		if !strings.Contains(vagrantfile, result) {
+			t.Fatalf("missing %s: %s", result, vagrantfile)
+		}
+	}
+	if metadata["image"] != image || metadata["platform"] != "linux/arm64" {
+		t.Fatalf("bad metadata: %#v", metadata)
+	}
+}
+
+func TestDockerImage(t *testing.T) {
+	cases := []struct {
+		state    map[string]interface{}
+		id       string
+		expected string
+	}{
+		{nil, "sha256:1234", "sha256:1234"},
+		{map[string]interface{}{"docker_tags": []string{"app:1.0", "app:latest"}}, "sha256:1234", "app:1.0"},
+		{map[string]interface{}{"digest": "app@sha256:abcd"}, "app:1.0", "app@sha256:abcd"},
+		{map[string]interface{}{"digest": "sha256:abcd"}, "app:1.0", "app@sha256:abcd"},
+		{map[string]interface{}{"digest": "sha256:abcd"}, "sha256:1234", "sha256:1234"},
+		{map[string]interface{}{"docker_tags": []string{"app"}, "digest": "sha256:abcd"}, "sha256:1234", "app@sha256:abcd"},
+	}
+	for _, tc := range cases {
+		artifact := &packersdk.MockArtifact{IdValue: tc.id, StateValues: tc.state}
+		ui := testUi()
+		if image := dockerImage(ui, artifact); image != tc.expected {
+			t.Errorf("%v: expected %q, got %q", tc.state, tc.expected, image)
+		}
+		// Digests that can't be pinned are reported
+		unpinned := !strings.Contains(tc.expected, "@") && tc.state["digest"] != nil
+		if warned := strings.Contains(ui.Writer.(*bytes.Buffer).String(), "can't be pinned"); warned != unpinned {
+			t.Errorf("%v: expected a warning: %t", tc.state, unpinned)
+		}
+	}
+}
+
+func TestDockerProvider_Process_hasSSH(t *testing.T) {
+	artifact := &packersdk.MockArtifact{IdValue: "app:1.0"}
+	p := new(DockerProvider)
+	p.SetHasSSH(true)
+	vagrantfile, _, _, err := p.Process(testUi(), artifact, "foo")
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if !strings.Contains(vagrantfile, "docker.has_ssh = true") || strings.Contains(vagrantfile, "communicator") {
+		t.Fatalf("bad Vagrantfile: %s", vagrantfile)
+	}
 }
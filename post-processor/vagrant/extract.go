@@ -0,0 +1,206 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package vagrant
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// extractLimits bound what an archive may unpack to, so that a crafted one
+// can't exhaust the disk of the host.
+type extractLimits struct {
+	// MaxEntries is the number of entries the archive may have.
+	MaxEntries int
+	// MaxSize is the total size of the files in the archive, holes of
+	// sparse files included.
+	MaxSize int64
+}
+
+var defaultExtractLimits = extractLimits{
+	MaxEntries: 10000,
+	MaxSize:    4 << 40,
+}
+
+// archivePath checks that the name of an archive entry stays inside of the
+// directory it is extracted to, and returns it cleaned up, with slashes.
+func archivePath(name string) (string, error) {
+	slashed := strings.ReplaceAll(name, `\`, "/")
+	if name == "" || path.IsAbs(slashed) || filepath.IsAbs(name) || filepath.VolumeName(name) != "" {
+		return "", fmt.Errorf("Illegal absolute path in archive: %q", name)
+	}
+	for _, part := range strings.Split(slashed, "/") {
+		if part == ".." {
+			return "", fmt.Errorf("Illegal path with '..' in archive: %q", name)
+		}
+	}
+	return path.Clean(slashed), nil
+}
+
+// walkTar goes through the entries of a tar archive, checking their names
+// and sizes, and calls fn with the cleaned up name of each of them. The
+// contents of a file can be read from tr while fn runs.
+func walkTar(tr *tar.Reader, limits extractLimits, fn func(name string, hdr *tar.Header) error) error {
+	var entries int
+	var size int64
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		entries++
+		if entries > limits.MaxEntries {
+			return fmt.Errorf("Archive has more than %d entries", limits.MaxEntries)
+		}
+		name, err := archivePath(hdr.Name)
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			size += hdr.Size
+			if hdr.Size < 0 || size > limits.MaxSize {
+				return fmt.Errorf("Archive holds more than %d bytes", limits.MaxSize)
+			}
+		}
+
+		if err := fn(name, hdr); err != nil {
+			return err
+		}
+	}
+}
+
+// extractTar extracts a tar archive into dir, keeping its directory
+// structure. Symlinks are only created when they point inside of dir, and
+// nothing is extracted through one. Hard links may only point to files
+// extracted before them. Other special files are skipped.
+func extractTar(r io.Reader, dir string, limits extractLimits) error {
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		return err
+	}
+	defer root.Close()
+
+	tr := tar.NewReader(r)
+	return walkTar(tr, limits, func(name string, hdr *tar.Header) error {
+		if name == "." {
+			return nil
+		}
+		if err := checkNoSymlinks(root, name); err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeDir {
+			if err := root.MkdirAll(path.Dir(name), 0755); err != nil {
+				return err
+			}
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			return root.MkdirAll(name, 0755)
+		case tar.TypeReg:
+			return extractFile(root, name, hdr, tr)
+		case tar.TypeSymlink:
+			if path.IsAbs(hdr.Linkname) {
+				return fmt.Errorf("Illegal absolute symlink in archive: %q -> %q", hdr.Name, hdr.Linkname)
+			}
+			if _, err := archivePath(path.Join(path.Dir(name), hdr.Linkname)); err != nil {
+				return fmt.Errorf("Illegal symlink out of the archive: %q -> %q", hdr.Name, hdr.Linkname)
+			}
+			return root.Symlink(hdr.Linkname, name)
+		case tar.TypeLink:
+			target, err := archivePath(hdr.Linkname)
+			if err != nil {
+				return err
+			}
+			info, err := root.Lstat(target)
+			if err != nil || !info.Mode().IsRegular() {
+				return fmt.Errorf("Illegal hard link in archive: %q -> %q", hdr.Name, hdr.Linkname)
+			}
+			return root.Link(target, name)
+		default:
+			log.Printf("Skipping '%s' of unsupported type '%c'", hdr.Name, hdr.Typeflag)
+			return nil
+		}
+	})
+}
+
+// extractFile writes the current file of the tar reader to name, in root.
+func extractFile(root *os.Root, name string, hdr *tar.Header, tr io.Reader) error {
+	output, err := root.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer output.Close()
+
+	_ = root.Chmod(name, hdr.FileInfo().Mode().Perm())
+	if _, err := copySparse(output, tr); err != nil {
+		return err
+	}
+	if err := output.Close(); err != nil {
+		return err
+	}
+	_ = root.Chtimes(name, hdr.AccessTime, hdr.ModTime)
+	return nil
+}
+
+// checkNoSymlinks makes sure neither name nor any of its parents is a
+// symlink, since they could lead elsewhere than the archive meant to.
+func checkNoSymlinks(root *os.Root, name string) error {
+	for p := name; p != "."; p = path.Dir(p) {
+		info, err := root.Lstat(p)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("Illegal path through a symlink in archive: %q", name)
+		}
+	}
+	return nil
+}
+
+// openTarEntry opens the index-th entry of a tar archive, checking that it
+// is still the named file.
+func openTarEntry(src string, index int, name string) (io.ReadCloser, error) {
+	f, err := os.Open(src)
+	if err != nil {
+		return nil, err
+	}
+
+	tr := tar.NewReader(f)
+	for i := 0; ; i++ {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			err = fmt.Errorf("%s is missing from %s", name, src)
+		}
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		if i < index {
+			continue
+		}
+		if entryName, _ := archivePath(hdr.Name); entryName != name {
+			f.Close()
+			return nil, fmt.Errorf("%s is missing from %s", name, src)
+		}
+		return struct {
+			io.Reader
+			io.Closer
+		}{tr, f}, nil
+	}
+}
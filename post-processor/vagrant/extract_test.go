@@ -0,0 +1,142 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package vagrant
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type tarEntry struct {
+	Name     string
+	Type     byte
+	Body     string
+	Linkname string
+}
+
+func testTar(t *testing.T, entries []tarEntry) *bytes.Buffer {
+	buf := new(bytes.Buffer)
+	tw := tar.NewWriter(buf)
+	for _, e := range entries {
+		hdr := &tar.Header{
+			Name:     e.Name,
+			Typeflag: e.Type,
+			Linkname: e.Linkname,
+			Mode:     0644,
+			Size:     int64(len(e.Body)),
+		}
+		if e.Type != tar.TypeReg {
+			hdr.Size = 0
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(e.Body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf
+}
+
+func TestExtractTar(t *testing.T) {
+	dir := t.TempDir()
+	archive := testTar(t, []tarEntry{
+		{Name: "box.ovf", Type: tar.TypeReg, Body: "ovf"},
+		{Name: "disks/", Type: tar.TypeDir},
+		{Name: "disks/disk1.vmdk", Type: tar.TypeReg, Body: "disk"},
+		{Name: "nested/dir/file", Type: tar.TypeReg, Body: "nested"},
+		{Name: "disk.vmdk", Type: tar.TypeSymlink, Linkname: "disks/disk1.vmdk"},
+		{Name: "disks/disk2.vmdk", Type: tar.TypeLink, Linkname: "disks/disk1.vmdk"},
+		{Name: "fifo", Type: tar.TypeFifo},
+	})
+	if err := extractTar(archive, dir, defaultExtractLimits); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := map[string]string{
+		"box.ovf":          "ovf",
+		"disks/disk1.vmdk": "disk",
+		"disks/disk2.vmdk": "disk",
+		"nested/dir/file":  "nested",
+		"disk.vmdk":        "disk",
+	}
+	for name, body := range expected {
+		contents, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatalf("%s wasn't extracted: %s", name, err)
+		}
+		if string(contents) != body {
+			t.Fatalf("unexpected contents for %s: %q", name, contents)
+		}
+	}
+	if _, err := os.Lstat(filepath.Join(dir, "fifo")); err == nil {
+		t.Fatalf("special files should be skipped")
+	}
+}
+
+func TestExtractTar_illegal(t *testing.T) {
+	cases := map[string][]tarEntry{
+		"..": {
+			{Name: "../outside", Type: tar.TypeReg, Body: "data"},
+		},
+		"absolute path": {
+			{Name: "/tmp/outside", Type: tar.TypeReg, Body: "data"},
+		},
+		"symlink out": {
+			{Name: "dir/link", Type: tar.TypeSymlink, Linkname: "../../outside"},
+		},
+		"absolute symlink": {
+			{Name: "link", Type: tar.TypeSymlink, Linkname: "/etc"},
+		},
+		"through a symlink": {
+			{Name: "dir/", Type: tar.TypeDir},
+			{Name: "link", Type: tar.TypeSymlink, Linkname: "dir"},
+			{Name: "link/file", Type: tar.TypeReg, Body: "data"},
+		},
+		"hard link to a missing file": {
+			{Name: "link", Type: tar.TypeLink, Linkname: "missing"},
+		},
+		"hard link out": {
+			{Name: "link", Type: tar.TypeLink, Linkname: "../outside"},
+		},
+	}
+	for desc, entries := range cases {
+		t.Run(desc, func(t *testing.T) {
+			parent := t.TempDir()
+			dir := filepath.Join(parent, "dir")
+			if err := os.Mkdir(dir, 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := extractTar(testTar(t, entries), dir, defaultExtractLimits); err == nil {
+				t.Fatalf("should have error")
+			}
+			if _, err := os.Lstat(filepath.Join(parent, "outside")); err == nil {
+				t.Fatalf("a file was written outside of the directory")
+			}
+		})
+	}
+}
+
+func TestExtractTar_limits(t *testing.T) {
+	entries := []tarEntry{
+		{Name: "a", Type: tar.TypeReg, Body: "12345"},
+		{Name: "b", Type: tar.TypeReg, Body: "12345"},
+	}
+
+	err := extractTar(testTar(t, entries), t.TempDir(), extractLimits{MaxEntries: 1, MaxSize: 100})
+	if err == nil || !strings.Contains(err.Error(), "entries") {
+		t.Fatalf("expected too many entries, got %v", err)
+	}
+	err = extractTar(testTar(t, entries), t.TempDir(), extractLimits{MaxEntries: 10, MaxSize: 8})
+	if err == nil || !strings.Contains(err.Error(), "bytes") {
+		t.Fatalf("expected too many bytes, got %v", err)
+	}
+}
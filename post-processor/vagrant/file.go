@@ -16,18 +16,14 @@ func (p *FileProvider) KeepInputArtifact() bool {
 	return false
 }
 
-func (p *FileProvider) Process(ui packersdk.Ui, artifact packersdk.Artifact, dir string) (vagrantfile string, metadata map[string]interface{}, err error) {
+func (p *FileProvider) Process(ui packersdk.Ui, artifact packersdk.Artifact, dir string) (vagrantfile string, metadata map[string]interface{}, files []BoxFile, err error) {
 	// Create the metadata
 	metadata = map[string]interface{}{"provider": "file"}
 
-	// Copy all of the original contents into the temporary directory
+	// Add all of the original contents to the box
 	for _, path := range artifact.Files() {
-		ui.Message(fmt.Sprintf("Copying: %s", path))
-
-		dstPath := filepath.Join(dir, filepath.Base(path))
-		if err = CopyContents(dstPath, path); err != nil {
-			return
-		}
+		ui.Message(fmt.Sprintf("Adding: %s", path))
+		files = append(files, BoxFile{Name: filepath.Base(path), Path: path})
 	}
 
 	return
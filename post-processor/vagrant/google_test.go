@@ -29,7 +29,7 @@ func TestGoogleProvider_ArtifactId(t *testing.T) {
 		IdValue: "packer-1234",
 	}
 
-	vagrantfile, _, err := p.Process(ui, artifact, "foo")
+	vagrantfile, _, _, err := p.Process(ui, artifact, "foo")
 	if err != nil {
 		t.Fatalf("should not have error: %s", err)
 	}
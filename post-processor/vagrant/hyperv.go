@@ -4,10 +4,18 @@
 package vagrant
 
 import (
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"unicode/utf16"
 
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 )
@@ -18,61 +26,384 @@ func (p *HypervProvider) KeepInputArtifact() bool {
 	return false
 }
 
-func (p *HypervProvider) Process(ui packersdk.Ui, artifact packersdk.Artifact, dir string) (vagrantfile string, metadata map[string]interface{}, err error) {
+func (p *HypervProvider) Process(ui packersdk.Ui, artifact packersdk.Artifact, dir string) (vagrantfile string, metadata map[string]interface{}, files []BoxFile, err error) {
 	// Create the metadata
 	metadata = map[string]interface{}{"provider": "hyperv"}
 
-	// ui.Message(fmt.Sprintf("artifacts all: %+v", artifact))
+	// Vagrant requires the directory structure of an export of Hyper-V,
+	// which the builder creates in its output directory, so it is kept in
+	// the box
 	var outputDir string
+	if outputDir, err = hypervOutputDir(artifact); err != nil {
+		return
+	}
 
-	// Vargant requires specific dir structure for hyperv
-	// hyperv builder creates the structure in the output dir
-	// we have to keep the structure in a temp dir
-	// hack little bit but string in artifact usually have output dir
-	artifactString := artifact.String()
-	d := strings.Split(artifactString, ": ")
-	outputDir = d[1]
-	// ui.Message(fmt.Sprintf("artifact dir from string: %s", outputDir))
-
-	// Copy all of the original contents into the temporary directory
+	// Add all of the original contents to the box, keeping their place in
+	// the directory structure
+	var config, vmcx string
 	for _, path := range artifact.Files() {
-		ui.Message(fmt.Sprintf("Copying: %s", path))
-
 		var rel string
+		if rel, err = filepath.Rel(outputDir, path); err != nil {
+			err = fmt.Errorf("Error finding %s in %s: %s", path, outputDir, err)
+			return
+		}
 
-		rel, err = filepath.Rel(outputDir, filepath.Dir(path))
-		// ui.Message(fmt.Sprintf("rel is: %s", rel))
+		ui.Message(fmt.Sprintf("Adding: %s", path))
+		files = append(files, BoxFile{Name: rel, Path: path})
 
-		if err != nil {
-			ui.Message(fmt.Sprintf("err in: %s", rel))
+		if filepath.Base(filepath.Dir(path)) == "Virtual Machines" {
+			switch filepath.Ext(path) {
+			case ".xml":
+				config = path
+			case ".vmcx":
+				vmcx = path
+			}
+		}
+	}
+
+	// The settings the builder reports win over the configuration of the
+	// VM, of which only the XML one can be read: the .vmcx one of recent
+	// versions of Hyper-V is binary
+	hw := hypervHardware{}
+	if config != "" {
+		if hw, err = readHypervConfig(config); err != nil {
 			return
 		}
+	}
+	hw.readArtifact(artifact)
+	if missing := hw.missing(); config == "" && vmcx != "" && len(missing) > 0 {
+		ui.Message(fmt.Sprintf("Warning: the configuration of the VM is in the binary format of Hyper-V, "+
+			"which can't be read, and the artifact doesn't tell its %s: they are left out of the box "+
+			"metadata and Vagrantfile: %s", strings.Join(missing, ", "), vmcx))
+	}
+	for key, value := range hw.metadata() {
+		metadata[key] = value
+	}
+
+	vagrantfile = fmt.Sprintf(hypervVagrantfile, hw.vagrantfile())
+	return
+}
+
+// hypervExportDirs are the directories of an export of Hyper-V.
+var hypervExportDirs = map[string]bool{
+	"Virtual Machines":   true,
+	"Virtual Hard Disks": true,
+	"Snapshots":          true,
+}
 
-		dstDir := filepath.Join(dir, rel)
-		// ui.Message(fmt.Sprintf("dstdir is: %s", dstDir))
-		if _, err = os.Stat(dstDir); err != nil {
-			if err = os.MkdirAll(dstDir, 0755); err != nil {
-				ui.Message(fmt.Sprintf("err in creating: %s", dstDir))
-				return
+// hypervOutputDir returns the output directory of the Hyper-V builder the
+// files of the artifact are in, which is the parent of the directories of the
+// export, like "Virtual Machines". All of the files must be in it.
+func hypervOutputDir(artifact packersdk.Artifact) (string, error) {
+	paths := artifact.Files()
+	if len(paths) == 0 {
+		return "", errors.New("The Hyper-V artifact has no files")
+	}
+
+	var outputDir string
+	for _, path := range paths {
+		for dir := filepath.Dir(path); dir != filepath.Dir(dir); dir = filepath.Dir(dir) {
+			if hypervExportDirs[filepath.Base(dir)] {
+				outputDir = filepath.Dir(dir)
+				break
 			}
 		}
+		if outputDir != "" {
+			break
+		}
+	}
+	if outputDir == "" {
+		return "", fmt.Errorf("Can't find the output directory of the Hyper-V artifact: " +
+			"expected its files to be in the \"Virtual Machines\" and \"Virtual Hard Disks\" directories of an export")
+	}
+	for _, path := range paths {
+		if !isWithin(outputDir, path) {
+			return "", fmt.Errorf("The file %s of the Hyper-V artifact is not in its output directory %s", path, outputDir)
+		}
+	}
+	return outputDir, nil
+}
+
+// hypervSecureBootTemplates are the names of the secure boot templates of
+// Hyper-V, by ID.
+var hypervSecureBootTemplates = map[string]string{
+	"1734c6e8-3154-4dda-ba5f-a874cc483422": "MicrosoftWindows",
+	"272e7447-90a4-4563-a4b9-8e4ab00526ce": "MicrosoftUEFICertificateAuthority",
+	"4292ae2b-ee2c-42b5-a969-dd8f8689f6f3": "OpenSourceShieldedVM",
+}
+
+// hypervHardware is what the box keeps of the configuration of a Hyper-V
+// VM.
+type hypervHardware struct {
+	Generation         int
+	SecureBoot         bool
+	SecureBootTemplate string
+	CPUs               int
+	Memory             int
+	MaxMemory          int
+	Switch             string
+}
+
+// readHypervConfig reads the XML configuration of a VM exported by Hyper-V.
+func readHypervConfig(path string) (hypervHardware, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return hypervHardware{}, err
+	}
+
+	config, err := flattenXML(bytes.NewReader(decodeUTF16(data)))
+	if err != nil {
+		return hypervHardware{}, fmt.Errorf("Error reading %s: %s", filepath.Base(path), err)
+	}
+	return parseHypervConfig(config), nil
+}
 
-		dstPath := filepath.Join(dstDir, filepath.Base(path))
-
-		// We prefer to link the files where possible because they are often very huge.
-		// Some filesystem configurations do not allow hardlinks. As the possibilities
-		// of mounting different devices in different paths are flexible, we just try to
-		// link the file and copy if the link fails, thereby automatically optimizing with a safe fallback.
-		if err = LinkFile(dstPath, path); err != nil {
-			// ui.Message(fmt.Sprintf("err in linking: %s to %s", path, dstPath))
-			if err = CopyContents(dstPath, path); err != nil {
-				ui.Message(fmt.Sprintf("err in copying: %s to %s", path, dstPath))
-				return
+// decodeUTF16 converts a document in UTF-16 with a byte order mark, as
+// Hyper-V writes them, to UTF-8. Other documents are returned as is.
+func decodeUTF16(data []byte) []byte {
+	var order binary.ByteOrder
+	switch {
+	case bytes.HasPrefix(data, []byte{0xff, 0xfe}):
+		order = binary.LittleEndian
+	case bytes.HasPrefix(data, []byte{0xfe, 0xff}):
+		order = binary.BigEndian
+	default:
+		return data
+	}
+	units := make([]uint16, 0, len(data)/2)
+	for i := 2; i+1 < len(data); i += 2 {
+		units = append(units, order.Uint16(data[i:]))
+	}
+	return []byte(string(utf16.Decode(units)))
+}
+
+// flattenXML returns the text of the elements of an XML document, by their
+// slash separated path in lowercase, like
+// "configuration/settings/processors/count".
+func flattenXML(r io.Reader) (map[string]string, error) {
+	values := make(map[string]string)
+	decoder := xml.NewDecoder(r)
+	// The document is decoded to UTF-8 already, whatever its declaration
+	// says
+	decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+	var path []string
+	var text strings.Builder
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return values, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			path = append(path, strings.ToLower(t.Name.Local))
+			text.Reset()
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			if value := strings.TrimSpace(text.String()); value != "" {
+				values[strings.Join(path, "/")] = value
 			}
+			path = path[:len(path)-1]
+			text.Reset()
 		}
+	}
+}
 
-		ui.Message(fmt.Sprintf("Copied %s to %s", path, dstPath))
+// parseHypervConfig reads the hardware from the flattened XML configuration
+// of a VM.
+func parseHypervConfig(config map[string]string) hypervHardware {
+	integer := func(key string) int {
+		value, _ := strconv.Atoi(config[key])
+		return value
+	}
+	boolean := func(keys ...string) bool {
+		for _, key := range keys {
+			if value, err := strconv.ParseBool(config[key]); err == nil {
+				return value
+			}
+		}
+		return false
 	}
 
-	return
+	hw := hypervHardware{
+		// The subtype is 0 for generation 1 VMs, and 1 for generation 2 ones
+		Generation: integer("configuration/properties/subtype") + 1,
+		CPUs:       integer("configuration/settings/processors/count"),
+		Memory:     integer("configuration/settings/memory/bank/size"),
+	}
+	if boolean("configuration/settings/memory/bank/dynamic_memory_enabled") {
+		hw.MaxMemory = integer("configuration/settings/memory/bank/limit")
+	}
+	if hw.Generation == 2 {
+		hw.SecureBoot = boolean("configuration/secure_boot_enabled", "configuration/settings/secure_boot_enabled")
+	}
+	if hw.SecureBoot {
+		for _, key := range []string{"configuration/secure_boot_template_id", "configuration/settings/secure_boot_template_id"} {
+			if id := strings.ToLower(strings.Trim(config[key], "{}")); id != "" {
+				hw.SecureBootTemplate = id
+				if name, ok := hypervSecureBootTemplates[id]; ok {
+					hw.SecureBootTemplate = name
+				}
+			}
+		}
+	}
+
+	// The network adapters are in elements named after their ID, the first
+	// one connected to a switch is used
+	var keys []string
+	for key := range config {
+		if strings.HasSuffix(key, "/altswitchname") {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	if len(keys) > 0 {
+		hw.Switch = config[keys[0]]
+	}
+	return hw
 }
+
+// readArtifact sets the hardware from the state of the artifact, or else
+// from the data generated by its builder, when they tell it.
+func (hw *hypervHardware) readArtifact(artifact packersdk.Artifact) {
+	if generation, ok := artifactInt(artifact, "generation", "Generation"); ok {
+		hw.Generation = generation
+	}
+	if secureBoot, ok := artifactBool(artifact, "secure_boot", "SecureBoot"); ok {
+		hw.SecureBoot = secureBoot
+	}
+	if cpus, ok := artifactInt(artifact, "cpus", "CPUs"); ok {
+		hw.CPUs = cpus
+	}
+	if memory, ok := artifactInt(artifact, "memory", "Memory"); ok {
+		hw.Memory = memory
+	}
+	if name := artifactString(artifact, "switch", "SwitchName"); name != "" {
+		hw.Switch = name
+	}
+}
+
+// missing returns the settings of the hardware that aren't known.
+func (hw hypervHardware) missing() []string {
+	var missing []string
+	if hw.Generation == 0 {
+		missing = append(missing, "generation", "secure boot")
+	}
+	if hw.Memory == 0 {
+		missing = append(missing, "memory")
+	}
+	if hw.CPUs == 0 {
+		missing = append(missing, "CPUs")
+	}
+	if hw.Switch == "" {
+		missing = append(missing, "switch")
+	}
+	return missing
+}
+
+// artifactInt returns an integer of the state of the artifact, or else of
+// the data generated by its builder, and whether it is set. Numbers come
+// back in various types over RPC.
+func artifactInt(artifact packersdk.Artifact, key, generatedKey string) (int, bool) {
+	value := artifact.State(key)
+	if value == nil {
+		value = generatedValue(artifact, generatedKey)
+	}
+	switch v := value.(type) {
+	case int:
+		return v, true
+	case int64:
+		return int(v), true
+	case uint64:
+		return int(v), true
+	case float64:
+		return int(v), true
+	case string:
+		i, err := strconv.Atoi(v)
+		return i, err == nil
+	}
+	return 0, false
+}
+
+// artifactBool returns a boolean of the state of the artifact, or else of
+// the data generated by its builder, and whether it is set.
+func artifactBool(artifact packersdk.Artifact, key, generatedKey string) (bool, bool) {
+	value := artifact.State(key)
+	if value == nil {
+		value = generatedValue(artifact, generatedKey)
+	}
+	switch v := value.(type) {
+	case bool:
+		return v, true
+	case string:
+		b, err := strconv.ParseBool(v)
+		return b, err == nil
+	}
+	return false, false
+}
+
+// metadata returns the hardware as it is written to metadata.json, which is
+// nothing of the generation and secure boot when the generation isn't known.
+func (hw hypervHardware) metadata() map[string]interface{} {
+	metadata := make(map[string]interface{})
+	if hw.Generation > 0 {
+		metadata["generation"] = hw.Generation
+		metadata["secure_boot"] = hw.SecureBoot
+	}
+	if hw.SecureBootTemplate != "" {
+		metadata["secure_boot_template"] = hw.SecureBootTemplate
+	}
+	if hw.CPUs > 0 {
+		metadata["cpus"] = hw.CPUs
+	}
+	if hw.Memory > 0 {
+		metadata["memory"] = hw.Memory
+	}
+	if hw.Switch != "" {
+		metadata["switch"] = hw.Switch
+	}
+	return metadata
+}
+
+// vagrantfile returns the settings of the box Vagrantfile for the hardware.
+// The generation and secure boot template of the VM are kept by Vagrant when
+// it imports the VM. The switch is only recorded in the metadata, as Vagrant
+// asks for one when the VM is created.
+func (hw hypervHardware) vagrantfile() string {
+	var settings strings.Builder
+	settings.WriteString("  config.vm.provider \"hyperv\" do |h|\n")
+	if hw.Memory > 0 {
+		fmt.Fprintf(&settings, "    h.memory = %d\n", hw.Memory)
+	}
+	if hw.MaxMemory > 0 {
+		fmt.Fprintf(&settings, "    h.maxmemory = %d\n", hw.MaxMemory)
+	}
+	if hw.CPUs > 0 {
+		fmt.Fprintf(&settings, "    h.cpus = %d\n", hw.CPUs)
+	}
+	settings.WriteString(hypervIntegrationServices)
+	settings.WriteString("  end\n")
+	return settings.String()
+}
+
+// hypervIntegrationServices enables the integration services Vagrant relies
+// on, including the guest service interface Hyper-V disables by default.
+var hypervIntegrationServices = `    h.vm_integration_services = {
+      guest_service_interface: true,
+      heartbeat: true,
+      key_value_pair_exchange: true,
+      shutdown: true,
+      time_synchronization: true,
+      vss: true,
+    }
+`
+
+var hypervVagrantfile = `
+Vagrant.configure("2") do |config|
+%send
+`
@@ -0,0 +1,261 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package vagrant
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+const testHypervConfig = `<?xml version="1.0" encoding="UTF-16" standalone="yes"?>
+<configuration>
+  <_E3EE3C37-4B0A-4C5C-9BC2-27B14E5E8C8D_>
+    <AltSwitchName type="string">Default Switch</AltSwitchName>
+    <ChannelInstanceGuid type="string">{3FB8E4B8-9C39-4B87-9F3F-AC6A09D1E3E7}</ChannelInstanceGuid>
+  </_E3EE3C37-4B0A-4C5C-9BC2-27B14E5E8C8D_>
+  <properties>
+    <name type="string">packer-hyperv-iso</name>
+    <subtype type="integer">1</subtype>
+  </properties>
+  <secure_boot_enabled type="bool">True</secure_boot_enabled>
+  <secure_boot_template_id type="string">{272E7447-90A4-4563-A4B9-8E4AB00526CE}</secure_boot_template_id>
+  <settings>
+    <memory>
+      <bank>
+        <dynamic_memory_enabled type="bool">True</dynamic_memory_enabled>
+        <limit type="integer">4096</limit>
+        <reservation type="integer">512</reservation>
+        <size type="integer">2048</size>
+      </bank>
+    </memory>
+    <processors>
+      <count type="integer">2</count>
+    </processors>
+  </settings>
+</configuration>
+`
+
+func TestHypervProvider_impl(t *testing.T) {
+	var _ Provider = new(HypervProvider)
+}
+
+// testHypervExport writes the files of an export of Hyper-V into a
+// temporary directory, and returns their paths.
+func testHypervExport(t *testing.T, config string) (string, []string) {
+	outputDir := t.TempDir()
+	contents := map[string]string{
+		"Virtual Machines/E3EE3C37-4B0A-4C5C-9BC2-27B14E5E8C8D.xml": config,
+		"Virtual Hard Disks/packer-hyperv-iso.vhdx":                 "disk",
+	}
+	var paths []string
+	for name, content := range contents {
+		path := filepath.Join(outputDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		paths = append(paths, path)
+	}
+	return outputDir, paths
+}
+
+// testHypervVmcx renames the XML configuration of an export to a .vmcx one,
+// as recent versions of Hyper-V write.
+func testHypervVmcx(t *testing.T, paths []string) {
+	for i, path := range paths {
+		if filepath.Ext(path) == ".xml" {
+			vmcx := strings.TrimSuffix(path, ".xml") + ".vmcx"
+			if err := os.Rename(path, vmcx); err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			paths[i] = vmcx
+		}
+	}
+}
+
+func TestHypervProvider_Process(t *testing.T) {
+	_, paths := testHypervExport(t, testHypervConfig)
+	artifact := &packersdk.MockArtifact{FilesValue: paths}
+
+	vagrantfile, metadata, files, err := new(HypervProvider).Process(testUi(), artifact, t.TempDir())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	var names []string
+	for _, f := range files {
+		names = append(names, filepath.ToSlash(f.Name))
+	}
+	expectedNames := []string{
+		"Virtual Hard Disks/packer-hyperv-iso.vhdx",
+		"Virtual Machines/E3EE3C37-4B0A-4C5C-9BC2-27B14E5E8C8D.xml",
+	}
+	sort.Strings(names)
+	if !reflect.DeepEqual(names, expectedNames) {
+		t.Errorf("bad files: %v", names)
+	}
+
+	expected := map[string]interface{}{
+		"provider":             "hyperv",
+		"generation":           2,
+		"secure_boot":          true,
+		"secure_boot_template": "MicrosoftUEFICertificateAuthority",
+		"cpus":                 2,
+		"memory":               2048,
+		"switch":               "Default Switch",
+	}
+	if !reflect.DeepEqual(metadata, expected) {
+		t.Errorf("bad metadata: %#v", metadata)
+	}
+
+	// The switch is only recorded in the metadata
+	if strings.Contains(vagrantfile, "public_network") {
+		t.Errorf("Vagrantfile should not set the network:\n%s", vagrantfile)
+	}
+	for _, setting := range []string{
+		"h.memory = 2048",
+		"h.maxmemory = 4096",
+		"h.cpus = 2",
+		"guest_service_interface: true",
+	} {
+		if !strings.Contains(vagrantfile, setting) {
+			t.Errorf("Vagrantfile is missing %s:\n%s", setting, vagrantfile)
+		}
+	}
+}
+
+func TestHypervProvider_Process_vmcx(t *testing.T) {
+	outputDir, paths := testHypervExport(t, "")
+	testHypervVmcx(t, paths)
+
+	artifact := &packersdk.MockArtifact{FilesValue: paths}
+	ui := testUi()
+	vagrantfile, metadata, files, err := new(HypervProvider).Process(ui, artifact, t.TempDir())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if output := ui.Writer.(*bytes.Buffer).String(); !strings.Contains(output, "Warning: the configuration of the VM") {
+		t.Errorf("skipping the .vmcx configuration should be reported:\n%s", output)
+	}
+	if len(files) != 2 {
+		t.Fatalf("bad files: %#v", files)
+	}
+	for _, f := range files {
+		if !isWithin(outputDir, f.Path) || strings.HasPrefix(f.Name, "..") {
+			t.Errorf("bad file: %#v", f)
+		}
+	}
+	if !reflect.DeepEqual(metadata, map[string]interface{}{"provider": "hyperv"}) {
+		t.Errorf("bad metadata: %#v", metadata)
+	}
+	if !strings.Contains(vagrantfile, "h.vm_integration_services") || strings.Contains(vagrantfile, "h.memory") {
+		t.Errorf("bad Vagrantfile:\n%s", vagrantfile)
+	}
+}
+
+func TestHypervProvider_Process_state(t *testing.T) {
+	_, paths := testHypervExport(t, "")
+	testHypervVmcx(t, paths)
+
+	artifact := rpcArtifact(t, &packersdk.MockArtifact{
+		FilesValue: paths,
+		StateValues: map[string]interface{}{
+			"generation":  2,
+			"secure_boot": true,
+			"generated_data": map[string]interface{}{
+				"CPUs":       "4",
+				"Memory":     4096,
+				"SwitchName": "Default Switch",
+			},
+		},
+	})
+	ui := testUi()
+	vagrantfile, metadata, _, err := new(HypervProvider).Process(ui, artifact, t.TempDir())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if output := ui.Writer.(*bytes.Buffer).String(); strings.Contains(output, "Warning") {
+		t.Errorf("the settings of the artifact should be used instead of the .vmcx configuration:\n%s", output)
+	}
+
+	expected := map[string]interface{}{
+		"provider":    "hyperv",
+		"generation":  2,
+		"secure_boot": true,
+		"cpus":        4,
+		"memory":      4096,
+		"switch":      "Default Switch",
+	}
+	if !reflect.DeepEqual(metadata, expected) {
+		t.Errorf("bad metadata: %#v", metadata)
+	}
+	for _, setting := range []string{"h.memory = 4096", "h.cpus = 4"} {
+		if !strings.Contains(vagrantfile, setting) {
+			t.Errorf("Vagrantfile is missing %s:\n%s", setting, vagrantfile)
+		}
+	}
+}
+
+func TestHypervProvider_Process_stateOverXML(t *testing.T) {
+	_, paths := testHypervExport(t, testHypervConfig)
+	artifact := &packersdk.MockArtifact{
+		FilesValue:  paths,
+		StateValues: map[string]interface{}{"cpus": 8},
+	}
+
+	vagrantfile, metadata, _, err := new(HypervProvider).Process(testUi(), artifact, t.TempDir())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if metadata["cpus"] != 8 || metadata["memory"] != 2048 {
+		t.Errorf("bad metadata: %#v", metadata)
+	}
+	if !strings.Contains(vagrantfile, "h.cpus = 8") {
+		t.Errorf("bad Vagrantfile:\n%s", vagrantfile)
+	}
+}
+
+func TestHypervOutputDir_errors(t *testing.T) {
+	outputDir, paths := testHypervExport(t, testHypervConfig)
+
+	cases := []*packersdk.MockArtifact{
+		// No files
+		{FilesValue: []string{}},
+		// Files out of the export
+		{FilesValue: append(paths, filepath.Join(t.TempDir(), "disk.vhdx"))},
+		// Not the layout of an export of Hyper-V
+		{FilesValue: []string{filepath.Join(t.TempDir(), "disk.vhdx")}},
+	}
+	for _, artifact := range cases {
+		if _, err := hypervOutputDir(artifact); err == nil {
+			t.Errorf("expected an error with files %v", artifact.FilesValue)
+		}
+	}
+
+	// The output directory is the one of the export, wherever the
+	// description of the artifact says it is
+	artifact := &packersdk.MockArtifact{FilesValue: paths, StringValue: "VM files in directory: elsewhere"}
+	if dir, err := hypervOutputDir(artifact); err != nil || dir != outputDir {
+		t.Errorf("expected %s, got %s: %v", outputDir, dir, err)
+	}
+}
+
+func TestDecodeUTF16(t *testing.T) {
+	little := []byte{0xff, 0xfe, '<', 0, 'a', 0, '/', 0, '>', 0}
+	big := []byte{0xfe, 0xff, 0, '<', 0, 'a', 0, '/', 0, '>'}
+	for _, data := range [][]byte{little, big, []byte("<a/>")} {
+		if decoded := string(decodeUTF16(data)); decoded != "<a/>" {
+			t.Errorf("%v decoded as %q", data, decoded)
+		}
+	}
+}
@@ -4,7 +4,9 @@
 package vagrant
 
 import (
+	"errors"
 	"fmt"
+	"log"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -30,7 +32,10 @@ func lower(c byte) byte {
 //	E (exabyte)  1024P
 //
 // The default is M.
-func sizeInMegabytes(size string) uint64 {
+func sizeInMegabytes(size string) (uint64, error) {
+	if size == "" {
+		return 0, errors.New("Empty disk size")
+	}
 	unit := size[len(size)-1]
 
 	if unit >= '0' && unit <= '9' {
@@ -39,25 +44,28 @@ func sizeInMegabytes(size string) uint64 {
 		size = size[:len(size)-1]
 	}
 
-	value, _ := strconv.ParseUint(size, 10, 64)
+	value, err := strconv.ParseUint(size, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid disk size %q", size)
+	}
 
 	switch lower(unit) {
 	case 'b':
-		return value / 1024 / 1024
+		return value / 1024 / 1024, nil
 	case 'k':
-		return value / 1024
+		return value / 1024, nil
 	case 'm':
-		return value
+		return value, nil
 	case 'g':
-		return value * 1024
+		return value * 1024, nil
 	case 't':
-		return value * 1024 * 1024
+		return value * 1024 * 1024, nil
 	case 'p':
-		return value * 1024 * 1024 * 1024
+		return value * 1024 * 1024 * 1024, nil
 	case 'e':
-		return value * 1024 * 1024 * 1024 * 1024
+		return value * 1024 * 1024 * 1024 * 1024, nil
 	default:
-		panic(fmt.Sprintf("Unknown size unit %c", unit))
+		return 0, fmt.Errorf("Unknown size unit %c", unit)
 	}
 }
 
@@ -66,40 +74,70 @@ type LibVirtProvider struct{}
 func (p *LibVirtProvider) KeepInputArtifact() bool {
 	return false
 }
-func (p *LibVirtProvider) Process(ui packersdk.Ui, artifact packersdk.Artifact, dir string) (vagrantfile string, metadata map[string]interface{}, err error) {
+
+// stateString returns a string of the artifact state, which must be set when
+// required. Optional states of another type are skipped.
+func stateString(artifact packersdk.Artifact, key string, required bool) (string, error) {
+	raw := artifact.State(key)
+	if raw == nil {
+		if required {
+			return "", fmt.Errorf("The artifact has no %s state. Is it from the QEMU builder?", key)
+		}
+		return "", nil
+	}
+	value, ok := raw.(string)
+	if !ok {
+		if required {
+			return "", fmt.Errorf("The %s state of the artifact is a %T, not a string", key, raw)
+		}
+		log.Printf("Skipping the %s state of the artifact, a %T rather than a string", key, raw)
+	}
+	return value, nil
+}
+
+func (p *LibVirtProvider) Process(ui packersdk.Ui, artifact packersdk.Artifact, dir string) (vagrantfile string, metadata map[string]interface{}, files []BoxFile, err error) {
+	state := make(map[string]string)
+	for _, key := range []string{"diskType", "diskName", "domainType"} {
+		if state[key], err = stateString(artifact, key, true); err != nil {
+			return
+		}
+	}
+	if state["diskSize"], err = stateString(artifact, "diskSize", false); err != nil {
+		return
+	}
+
 	disks := []map[string]string{}
-	format := artifact.State("diskType").(string)
-	diskName := artifact.State("diskName").(string)
+	var firmware []string
 	disk_index := 0
 	for _, path := range artifact.Files() {
+		// The UEFI variables, and code if any, are kept next to the disks
+		if filepath.Ext(path) == ".fd" {
+			firmware = append(firmware, path)
+			continue
+		}
 		// DiskName is [vmName, vmName-1, vmName-2]
-		if !strings.HasPrefix(filepath.Base(path), diskName) {
+		if !strings.HasPrefix(filepath.Base(path), state["diskName"]) {
 			continue
 		}
-		ui.Message(fmt.Sprintf("Copying from artifact: %s", path))
+		ui.Message(fmt.Sprintf("Adding from artifact: %s", path))
 		dstDiskName := fmt.Sprintf("box_%d.img", disk_index)
-		dstPath := filepath.Join(dir, dstDiskName)
 		disks = append(disks, map[string]string{
 			"path":   dstDiskName,
-			"format": format,
+			"format": state["diskType"],
 		})
 		disk_index++
-		if err = CopyContents(dstPath, path); err != nil {
-			return
-		}
+		files = append(files, BoxFile{Name: dstDiskName, Path: path})
 	}
 
-	domainType := artifact.State("domainType").(string)
-
 	// Convert domain type to libvirt driver
 	var driver string
-	switch domainType {
+	switch domainType := state["domainType"]; domainType {
 	case "none", "tcg", "hvf":
 		driver = "qemu"
 	case "kvm":
 		driver = domainType
 	default:
-		return "", nil, fmt.Errorf("Unknown libvirt domain type: %s", domainType)
+		return "", nil, nil, fmt.Errorf("Unknown libvirt domain type: %s", domainType)
 	}
 
 	// Create the metadata
@@ -107,15 +145,92 @@ func (p *LibVirtProvider) Process(ui packersdk.Ui, artifact packersdk.Artifact,
 		"provider": "libvirt",
 		"disks":    disks,
 	}
+	if state["diskSize"] != "" {
+		var size uint64
+		if size, err = sizeInMegabytes(state["diskSize"]); err != nil {
+			err = fmt.Errorf("Error reading the disk size of the artifact: %s", err)
+			return
+		}
+		// In gigabytes, rounded up, as the first format of the provider had
+		// it
+		metadata["virtual_size"] = (size + 1023) / 1024
+	}
+
+	// The machine type, CPU model, disk interface and network device are
+	// read from the state of the artifact, or the data generated by the
+	// builder, when it tells them
+	settings := []string{fmt.Sprintf("libvirt.driver = %q", driver)}
+	if machineType := artifactString(artifact, "machineType", "MachineType"); machineType != "" {
+		settings = append(settings, fmt.Sprintf("libvirt.machine_type = %q", machineType))
+	}
+	switch cpuModel := artifactString(artifact, "cpuModel", "CPUModel"); cpuModel {
+	case "":
+	case "host":
+		settings = append(settings, `libvirt.cpu_mode = "host-passthrough"`)
+	default:
+		settings = append(settings, `libvirt.cpu_mode = "custom"`,
+			fmt.Sprintf("libvirt.cpu_model = %q", cpuModel))
+	}
+	if bus := libvirtDiskBus(artifactString(artifact, "diskInterface", "DiskInterface")); bus != "" {
+		settings = append(settings, fmt.Sprintf("libvirt.disk_bus = %q", bus))
+	}
+	if model := libvirtNicModel(artifactString(artifact, "netDevice", "NetDevice")); model != "" {
+		settings = append(settings, fmt.Sprintf("libvirt.nic_model_type = %q", model))
+	}
+	for _, path := range firmware {
+		name := filepath.Base(path)
+		ui.Message(fmt.Sprintf("Adding UEFI firmware from artifact: %s", path))
+		files = append(files, BoxFile{Name: name, Path: path})
+		option := "nvram"
+		if strings.Contains(strings.ToLower(name), "code") {
+			option = "loader"
+		}
+		metadata[option] = name
+		settings = append(settings, fmt.Sprintf("libvirt.%s = File.expand_path(%q, __dir__)", option, name))
+	}
 
-	vagrantfile = fmt.Sprintf(libvirtVagrantfile, driver)
+	vagrantfile = fmt.Sprintf(libvirtVagrantfile, "    "+strings.Join(settings, "\n    "))
 	return
 }
 
+// libvirtDiskBus returns the bus of vagrant-libvirt for a disk interface of
+// QEMU.
+func libvirtDiskBus(diskInterface string) string {
+	switch diskInterface {
+	case "virtio", "ide", "sata":
+		return diskInterface
+	case "virtio-scsi", "scsi":
+		return "scsi"
+	}
+	return ""
+}
+
+// libvirtNicModel returns the NIC model of vagrant-libvirt for a network
+// device of QEMU.
+func libvirtNicModel(netDevice string) string {
+	switch netDevice {
+	case "virtio-net", "virtio-net-pci", "virtio":
+		return "virtio"
+	case "":
+		return ""
+	}
+	return netDevice
+}
+
+// DetectArchitecture tells the architecture of the guest from the QEMU
+// binary the artifact was built with, or from its machine type, as set in
+// its state or in the data generated by the builder.
+func (p *LibVirtProvider) DetectArchitecture(artifact packersdk.Artifact) (string, error) {
+	if arch := qemuArchitecture(artifactString(artifact, "qemuBinary", "QemuBinary")); arch != "" {
+		return arch, nil
+	}
+	return qemuMachineArchitecture(artifactString(artifact, "machineType", "MachineType")), nil
+}
+
 var libvirtVagrantfile = `
 Vagrant.configure("2") do |config|
   config.vm.provider :libvirt do |libvirt|
-    libvirt.driver = "%s"
+%s
   end
 end
 `
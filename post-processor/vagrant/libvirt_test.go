@@ -6,6 +6,9 @@ package vagrant
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
@@ -13,20 +16,21 @@ import (
 )
 
 func assertSizeInMegabytes(t *testing.T, size string, expected uint64) {
-	actual := sizeInMegabytes(size)
+	actual, err := sizeInMegabytes(size)
+	if err != nil {
+		t.Fatalf("the size `%s` can't be converted: %s", size, err)
+	}
 	if actual != expected {
 		t.Fatalf("the size `%s` was converted to `%d` but expected `%d`", size, actual, expected)
 	}
 }
 
-func Test_sizeInMegabytes_WithInvalidUnitMustPanic(t *testing.T) {
-	defer func() {
-		if r := recover(); r == nil {
-			t.Fatalf("expected a panic but got none")
+func Test_sizeInMegabytes_WithInvalidSizeMustFail(t *testing.T) {
+	for _, size := range []string{"1234x", "", "M", "12.5G"} {
+		if _, err := sizeInMegabytes(size); err == nil {
+			t.Errorf("expected an error for the size %q", size)
 		}
-	}()
-
-	sizeInMegabytes("1234x")
+	}
 }
 
 func Test_sizeInMegabytes_WithoutUnitMustDefaultToMegabytes(t *testing.T) {
@@ -131,7 +135,7 @@ func Test_ManyFilesInArtifact(t *testing.T) {
 
 		dirProcess, _ := tmp.Dir("process")
 		defer os.RemoveAll(dirProcess)
-		_, metadata, err := p.Process(ui, artifact, dirProcess)
+		_, metadata, files, err := p.Process(ui, artifact, dirProcess)
 
 		if err != nil {
 			t.Fatalf("should not have error: %s", err)
@@ -148,7 +152,168 @@ func Test_ManyFilesInArtifact(t *testing.T) {
 			if tc.Format != disk["format"] {
 				t.Errorf("%s. Expected %#v", "Disk files format must be present", tc.Format)
 			}
+			if files[i].Name != disk["path"] {
+				t.Errorf("Disk %s must be added to the box as %s, got %s", files[i].Path, disk["path"], files[i].Name)
+			}
+		}
+	}
+
+}
+
+func TestLibVirtProvider_DetectArchitecture(t *testing.T) {
+	cases := []struct {
+		state    map[string]interface{}
+		expected string
+	}{
+		{map[string]interface{}{"qemuBinary": "qemu-system-aarch64", "machineType": "virt"}, "arm64"},
+		{map[string]interface{}{"machineType": "q35"}, "amd64"},
+		{map[string]interface{}{"machineType": "virt"}, ""},
+		// The data generated by the builder
+		{map[string]interface{}{
+			"diskName":   "disk",
+			"diskType":   "qcow2",
+			"domainType": "tcg",
+			"generated_data": map[interface{}]interface{}{
+				"QemuBinary":  "qemu-system-aarch64",
+				"MachineType": "virt",
+			},
+		}, "arm64"},
+		{map[string]interface{}{
+			"generated_data": map[string]interface{}{"MachineType": "pc-q35-8.2"},
+		}, "amd64"},
+		{nil, ""},
+	}
+	for _, tc := range cases {
+		artifact := &packersdk.MockArtifact{StateValues: tc.state}
+		arch, err := new(LibVirtProvider).DetectArchitecture(artifact)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if arch != tc.expected {
+			t.Errorf("%v: expected %q, got %q", tc.state, tc.expected, arch)
+		}
+	}
+}
+
+func TestLibVirtProvider_Process_missingState(t *testing.T) {
+	state := map[string]interface{}{
+		"diskType":   "qcow2",
+		"diskName":   "test",
+		"domainType": "kvm",
+	}
+	for key := range state {
+		artifact := &packersdk.MockArtifact{StateValues: map[string]interface{}{}}
+		for k, v := range state {
+			if k != key {
+				artifact.StateValues[k] = v
+			}
+		}
+		_, _, _, err := new(LibVirtProvider).Process(testUi(), artifact, t.TempDir())
+		if err == nil || !strings.Contains(err.Error(), key) {
+			t.Errorf("expected an error about %s, got %v", key, err)
+		}
+	}
+
+	artifact := &packersdk.MockArtifact{StateValues: map[string]interface{}{
+		"diskType":   "qcow2",
+		"diskName":   "test",
+		"domainType": "kvm",
+		"diskSize":   "10x",
+	}}
+	if _, _, _, err := new(LibVirtProvider).Process(testUi(), artifact, t.TempDir()); err == nil {
+		t.Fatal("expected an error for an invalid disk size")
+	}
+
+	// Optional states of another type are skipped
+	artifact.StateValues["diskSize"] = uint64(10240)
+	_, metadata, _, err := new(LibVirtProvider).Process(testUi(), artifact, t.TempDir())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, ok := metadata["virtual_size"]; ok {
+		t.Fatalf("bad metadata: %#v", metadata)
+	}
+}
+
+func TestLibVirtProvider_Process_metadata(t *testing.T) {
+	dir := t.TempDir()
+	var artifactFiles []string
+	for _, name := range []string{"test", "OVMF_CODE.fd", "efivars.fd"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatalf("err: %s", err)
 		}
+		artifactFiles = append(artifactFiles, path)
+	}
+	artifact := &packersdk.MockArtifact{
+		FilesValue: artifactFiles,
+		StateValues: map[string]interface{}{
+			"diskType":    "qcow2",
+			"diskSize":    "40961M",
+			"diskName":    "test",
+			"diskPaths":   []string{artifactFiles[0]},
+			"domainType":  "tcg",
+			"machineType": "q35",
+			"generated_data": map[interface{}]interface{}{
+				"CPUModel":      "host",
+				"DiskInterface": "virtio-scsi",
+				"NetDevice":     "virtio-net",
+			},
+		},
 	}
 
+	vagrantfile, metadata, files, err := new(LibVirtProvider).Process(testUi(), artifact, t.TempDir())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if metadata["virtual_size"] != uint64(41) {
+		t.Errorf("bad virtual_size: %#v", metadata["virtual_size"])
+	}
+	if metadata["loader"] != "OVMF_CODE.fd" || metadata["nvram"] != "efivars.fd" {
+		t.Errorf("bad firmware: %#v", metadata)
+	}
+
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"box_0.img", "OVMF_CODE.fd", "efivars.fd"}) {
+		t.Errorf("bad files: %v", names)
+	}
+
+	for _, setting := range []string{
+		`libvirt.driver = "qemu"`,
+		`libvirt.machine_type = "q35"`,
+		`libvirt.cpu_mode = "host-passthrough"`,
+		`libvirt.disk_bus = "scsi"`,
+		`libvirt.nic_model_type = "virtio"`,
+		`libvirt.loader = File.expand_path("OVMF_CODE.fd", __dir__)`,
+		`libvirt.nvram = File.expand_path("efivars.fd", __dir__)`,
+	} {
+		if !strings.Contains(vagrantfile, setting) {
+			t.Errorf("Vagrantfile is missing %s:\n%s", setting, vagrantfile)
+		}
+	}
+}
+
+func TestLibVirtProvider_Process_defaults(t *testing.T) {
+	artifact := &packersdk.MockArtifact{
+		StateValues: map[string]interface{}{
+			"diskType":   "qcow2",
+			"diskName":   "test",
+			"domainType": "kvm",
+		},
+	}
+
+	// Settings the builder doesn't tell are left to vagrant-libvirt
+	vagrantfile, _, _, err := new(LibVirtProvider).Process(testUi(), artifact, t.TempDir())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	for _, setting := range []string{"machine_type", "cpu_mode", "disk_bus", "nic_model_type"} {
+		if strings.Contains(vagrantfile, setting) {
+			t.Errorf("Vagrantfile shouldn't set %s:\n%s", setting, vagrantfile)
+		}
+	}
 }
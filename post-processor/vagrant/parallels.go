@@ -5,15 +5,28 @@ package vagrant
 
 import (
 	"fmt"
+	"os"
 	"path/filepath"
 	"regexp"
+	"strings"
 
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 )
 
-// These are the extensions of files and directories that are unnecessary for the function
-// of a Parallels virtual machine.
-var UnnecessaryFilesPatterns = []string{"\\.log$", "\\.backup$", "\\.Backup$", "\\.app/", "/Windows Disks/"}
+// UnnecessaryFilesPatterns are regular expressions matching the files that
+// are unnecessary for the function of a Parallels virtual machine.
+//
+// Deprecated: the files are left out of the box by the default exclude
+// patterns of the parallels provider, which these are made from.
+var UnnecessaryFilesPatterns = parallelsUnnecessaryFilesPatterns()
+
+func parallelsUnnecessaryFilesPatterns() []string {
+	var patterns []string
+	for _, pattern := range defaultExcludes["parallels"] {
+		patterns = append(patterns, excludeRegexp(pattern))
+	}
+	return patterns
+}
 
 type ParallelsProvider struct{}
 
@@ -21,48 +34,45 @@ func (p *ParallelsProvider) KeepInputArtifact() bool {
 	return false
 }
 
-func (p *ParallelsProvider) Process(ui packersdk.Ui, artifact packersdk.Artifact, dir string) (vagrantfile string, metadata map[string]interface{}, err error) {
+func (p *ParallelsProvider) Process(ui packersdk.Ui, artifact packersdk.Artifact, dir string) (vagrantfile string, metadata map[string]interface{}, files []BoxFile, err error) {
 	// Create the metadata
 	metadata = map[string]interface{}{"provider": "parallels"}
 
-	var copied int
-
-	// Copy all of the original contents into the temporary directory
+	// Add the files of the VM bundle to the box, keeping their place in it
+	pathRe := regexp.MustCompile(`[^/]+\.(pvm|macvm)/.+$`)
 	for _, path := range artifact.Files() {
-		// If the file isn't critical to the function of the
-		// virtual machine, we get rid of it.
-		unnecessary := false
-		for _, unnecessaryPat := range UnnecessaryFilesPatterns {
-			if matched, _ := regexp.MatchString(unnecessaryPat, path); matched {
-				unnecessary = true
-				break
-			}
+		match := pathRe.FindString(filepath.ToSlash(path))
+		if match == "" {
+			continue // Just add a pvm
 		}
-		if unnecessary {
-			continue
+		var info os.FileInfo
+		if info, err = os.Stat(path); err != nil {
+			return
 		}
-
-		tmpPath := filepath.ToSlash(path)
-		pathRe := regexp.MustCompile(`[^/]+\.(pvm|macvm)/.+$`)
-		match := pathRe.FindString(tmpPath)
-		var pvmPath string
-		if match != "" {
-			pvmPath = filepath.FromSlash(match)
-		} else {
-			continue // Just copy a pvm
+		if info.IsDir() {
+			continue
 		}
-		dstPath := filepath.Join(dir, pvmPath)
 
-		ui.Message(fmt.Sprintf("Copying: %s", path))
-		if err = CopyContents(dstPath, path); err != nil {
-			return
-		}
-		copied++
+		ui.Message(fmt.Sprintf("Adding: %s", path))
+		files = append(files, BoxFile{Name: filepath.FromSlash(match), Path: path})
 	}
 
-	if copied == 0 {
+	if len(files) == 0 {
 		err = fmt.Errorf("No VM file found in source artifact")
 	}
 
 	return
 }
+
+// DetectArchitecture tells whether the artifact is a .macvm bundle, which
+// only exist on Apple silicon.
+func (p *ParallelsProvider) DetectArchitecture(artifact packersdk.Artifact) (string, error) {
+	for _, path := range artifact.Files() {
+		for _, part := range strings.Split(filepath.ToSlash(path), "/") {
+			if filepath.Ext(part) == ".macvm" {
+				return "arm64", nil
+			}
+		}
+	}
+	return "", nil
+}
@@ -9,6 +9,8 @@ import (
 	"io/ioutil"
 	"math/rand"
 	"os"
+	"path/filepath"
+	"regexp"
 	"testing"
 
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
@@ -18,6 +20,27 @@ func TestParallelsProvider_impl(t *testing.T) {
 	var _ Provider = new(ParallelsProvider)
 }
 
+func TestUnnecessaryFilesPatterns(t *testing.T) {
+	for path, unnecessary := range map[string]bool{
+		"/output/packer.pvm/parallels.log":                 true,
+		"/output/packer.pvm/config.pvs.backup":             true,
+		"/output/packer.pvm/Windows Disks/C":               true,
+		"/output/packer.pvm/Tools.app/Contents/Info.plist": true,
+		"/output/packer.pvm/config.pvs":                    false,
+		"/output/packer.pvm/harddisk.hdd/harddisk.hds":     false,
+	} {
+		var matched bool
+		for _, pattern := range UnnecessaryFilesPatterns {
+			if matched, _ = regexp.MatchString(pattern, path); matched {
+				break
+			}
+		}
+		if matched != unnecessary {
+			t.Errorf("%s: expected to be unnecessary: %t", path, unnecessary)
+		}
+	}
+}
+
 // mockParallelsVMDir creates a fake temp dir for parallels testing
 //
 // Note: the path to the pvm/macvm dir is returned, the responsibility to remove
@@ -116,3 +139,45 @@ func TestPostProcessorPostProcessParallels_NoFileErrorOnCopy(t *testing.T) {
 	}
 	t.Logf("failed as expected: %s", err)
 }
+
+func TestParallelsProvider_DetectArchitecture(t *testing.T) {
+	p := new(ParallelsProvider)
+	artifact := &packersdk.MockArtifact{FilesValue: []string{"/output/packer.macvm/config.plist"}}
+	if arch, _ := p.DetectArchitecture(artifact); arch != "arm64" {
+		t.Fatalf("expected arm64 for a .macvm, got %q", arch)
+	}
+	artifact = &packersdk.MockArtifact{FilesValue: []string{"/output/packer.pvm/config.pvs"}}
+	if arch, _ := p.DetectArchitecture(artifact); arch != "" {
+		t.Fatalf("expected no architecture for a .pvm, got %q", arch)
+	}
+}
+
+func TestParallelsProvider_Process(t *testing.T) {
+	inputVM, err := mockParallelsVMDir()
+	if err != nil {
+		t.Fatalf("failed to create parallels VM directory")
+	}
+	defer os.RemoveAll(inputVM[0])
+
+	dir := t.TempDir()
+	artifact := &packersdk.MockArtifact{FilesValue: inputVM}
+	_, _, files, err := new(ParallelsProvider).Process(testUi(), artifact, dir)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if len(files) != 2 {
+		t.Fatalf("expected the 2 files of the VM, got %#v", files)
+	}
+	for i, f := range files {
+		expected := filepath.Join(filepath.Base(inputVM[0]), filepath.Base(inputVM[i+1]))
+		if f.Name != expected || f.Path != inputVM[i+1] {
+			t.Errorf("expected %s to be added as %s, got %#v", inputVM[i+1], expected, f)
+		}
+	}
+
+	// The files are streamed into the box, not copied
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("expected nothing to be written to %s, got %v", dir, entries)
+	}
+}
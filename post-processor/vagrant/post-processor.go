@@ -11,11 +11,14 @@ package vagrant
 import (
 	"compress/flate"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"log"
 	"os"
+	"path"
 	"path/filepath"
-	"runtime"
+	"slices"
 	"strings"
 	"text/template"
 
@@ -47,6 +50,15 @@ var builtins = map[string]string{
 	"packer.post-processor.docker-tag":    "docker",
 	"packer.post-processor.docker-push":   "docker",
 	"packer.file":                         "file",
+	"jetbrains.vsphere":                   "vsphere",
+	"proxmox.iso":                         "proxmox",
+	"proxmox.clone":                       "proxmox",
+}
+
+// overrideProviders are the providers no builder maps to, which are only
+// selected with provider_override.
+var overrideProviders = []string{
+	"qemu",
 }
 
 var vagrantArchMap = map[string]string{
@@ -59,6 +71,9 @@ func availableProviders() []string {
 	for _, v := range builtins {
 		dedupedProvidersMap[v] = v
 	}
+	for _, v := range overrideProviders {
+		dedupedProvidersMap[v] = v
+	}
 
 	dedupedProviders := []string{}
 	for k := range dedupedProvidersMap {
@@ -72,19 +87,27 @@ type Config struct {
 	common.PackerConfig `mapstructure:",squash"`
 
 	CompressionLevel             int      `mapstructure:"compression_level"`
+	Format                       string   `mapstructure:"format"`
+	Reproducible                 bool     `mapstructure:"reproducible"`
 	Include                      []string `mapstructure:"include"`
+	Exclude                      []string `mapstructure:"exclude"`
 	OutputPath                   string   `mapstructure:"output"`
 	Override                     map[string]interface{}
 	VagrantfileTemplate          string `mapstructure:"vagrantfile_template"`
 	VagrantfileTemplateGenerated bool   `mapstructure:"vagrantfile_template_generated"`
 	ProviderOverride             string `mapstructure:"provider_override"`
 	Architecture                 string `mapstructure:"architecture"`
+	HasSSH                       bool   `mapstructure:"has_ssh"`
 
 	ctx interpolate.Context
 }
 
 type PostProcessor struct {
 	config Config
+
+	// detectArchitecture is set when the architecture isn't configured, so
+	// that the one of the guest is used when the provider can tell it
+	detectArchitecture bool
 }
 
 func (p *PostProcessor) ConfigSpec() hcldec.ObjectSpec {
@@ -96,11 +119,9 @@ func (p *PostProcessor) Configure(raws ...interface{}) error {
 		return err
 	}
 
-	if p.config.Architecture == "" {
-		p.config.Architecture = runtime.GOARCH
-		if mappedArch, ok := vagrantArchMap[p.config.Architecture]; ok {
-			p.config.Architecture = mappedArch
-		}
+	p.detectArchitecture = p.config.Architecture == ""
+	if p.detectArchitecture {
+		p.config.Architecture = hostArchitecture()
 	}
 
 	if p.config.ProviderOverride != "" {
@@ -127,13 +148,31 @@ func (p *PostProcessor) PostProcessProvider(name string, provider Provider, ui p
 		return nil, false, err
 	}
 
-	err = CreateDummyBox(ui, config.CompressionLevel)
+	boxOptions, err := config.boxOptions()
+	if err != nil {
+		return nil, false, err
+	}
+
+	err = CreateDummyBox(ui, boxOptions)
 	if err != nil {
 		return nil, false, err
 	}
 
 	ui.Say(fmt.Sprintf("Creating Vagrant box for '%s' provider", name))
 
+	// Unless set, the architecture is the one of the guest when the provider
+	// can tell it, rather than the one of the host
+	if detector, ok := provider.(ArchitectureDetector); ok && p.detectArchitecture && !config.overrides(name, "architecture") {
+		arch, err := detector.DetectArchitecture(artifact)
+		if err != nil {
+			log.Printf("Error detecting the architecture of the guest: %s", err)
+		}
+		if arch != "" {
+			ui.Message(fmt.Sprintf("Detected architecture: %s", arch))
+			config.Architecture = arch
+		}
+	}
+
 	var generatedData map[interface{}]interface{}
 	stateData := artifact.State("generated_data")
 	if stateData != nil {
@@ -163,28 +202,58 @@ func (p *PostProcessor) PostProcessProvider(name string, provider Provider, ui p
 	}
 	defer os.RemoveAll(dir)
 
-	// Copy all of the includes files into the temporary directory
+	// Files matching the default patterns of the provider, or the ones of
+	// the configuration, are left out of the box
+	excludes := slices.Concat(defaultExcludes[name], config.Exclude)
+
+	// Add all of the includes files to the box
+	var includes []BoxFile
 	for _, src := range config.Include {
-		ui.Message(fmt.Sprintf("Copying from include: %s", src))
-		dst := filepath.Join(dir, filepath.Base(src))
-		if err := CopyContents(dst, src); err != nil {
-			err = fmt.Errorf("Error copying include file: %s\n\n%s", src, err)
+		ui.Message(fmt.Sprintf("Including: %s", src))
+		boxFiles, err := includeFiles(src, excludes)
+		if err != nil {
 			return nil, false, err
 		}
+		includes = append(includes, boxFiles...)
 	}
 
 	// Run the provider processing step
-	vagrantfile, metadata, err := provider.Process(ui, artifact, dir)
+	if setter, ok := provider.(ArchitectureSetter); ok {
+		setter.SetArchitecture(config.Architecture)
+	}
+	if setter, ok := provider.(SSHSetter); ok {
+		setter.SetHasSSH(config.HasSSH)
+	}
+	vagrantfile, metadata, providerFiles, err := provider.Process(ui, newFilteredArtifact(artifact, excludes), dir)
 	if err != nil {
 		return nil, false, err
 	}
+	providerFiles = filterBoxFiles(excludes, providerFiles)
+
+	// The Vagrantfile is always generated below, and a metadata.json file
+	// coming from the artifact is used as is.
+	var files []BoxFile
+	var metadataFile *BoxFile
+	for _, f := range append(includes, providerFiles...) {
+		switch filepath.Clean(f.Name) {
+		case "Vagrantfile":
+			continue
+		case "metadata.json":
+			metadataFile = &f
+		}
+		files = append(files, f)
+	}
 
 	// Add architecture to metadata
 	metadata["architecture"] = config.Architecture
 
 	// Write the metadata we got
-	if err := WriteMetadata(dir, metadata); err != nil {
-		return nil, false, err
+	if metadataFile == nil {
+		if err := WriteMetadata(dir, metadata); err != nil {
+			return nil, false, err
+		}
+	} else if metadata, err = readMetadata(*metadataFile); err != nil {
+		return nil, false, fmt.Errorf("Error reading metadata.json: %s", err)
 	}
 
 	// Write our Vagrantfile
@@ -218,11 +287,54 @@ func (p *PostProcessor) PostProcessProvider(name string, provider Provider, ui p
 	}
 
 	// Create the box
-	if err := DirToBox(outputPath, dir, ui, config.CompressionLevel); err != nil {
+	digests, manifest, err := DirToBox(outputPath, dir, files, ui, boxOptions)
+	if err != nil {
 		return nil, false, err
 	}
 
-	return NewArtifact(name, outputPath), provider.KeepInputArtifact(), nil
+	ui.Message("Validating box")
+	if err := ValidateBox(outputPath, manifest); err != nil {
+		os.Remove(outputPath)
+		return nil, false, fmt.Errorf("Box %s is invalid: %s", outputPath, err)
+	}
+
+	checksumPath, err := WriteChecksumFile(outputPath, digests["sha256"])
+	if err != nil {
+		return nil, false, err
+	}
+	ui.Message(fmt.Sprintf("Box sha256: %s", digests["sha256"]))
+
+	info, err := os.Stat(outputPath)
+	if err != nil {
+		return nil, false, err
+	}
+
+	architecture, _ := metadata["architecture"].(string)
+	boxProvider, _ := metadata["provider"].(string)
+
+	// The state is handed to the next post-processors over RPC, which can
+	// only carry basic types and map[string]string, so the metadata goes as
+	// JSON and the generated data as strings.
+	metadataJSON, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, false, err
+	}
+	stateGeneratedData := make(map[string]string, len(generatedData))
+	for k, v := range generatedData {
+		stateGeneratedData[fmt.Sprint(k)] = fmt.Sprint(v)
+	}
+
+	box := NewArtifact(name, outputPath)
+	box.ChecksumPath = checksumPath
+	box.StateData = map[string]interface{}{
+		"metadata":       string(metadataJSON),
+		"architecture":   architecture,
+		"provider":       boxProvider,
+		"size":           info.Size(),
+		"digests":        digests,
+		"generated_data": stateGeneratedData,
+	}
+	return box, provider.KeepInputArtifact(), nil
 }
 
 func (p *PostProcessor) PostProcess(ctx context.Context, ui packersdk.Ui, artifact packersdk.Artifact) (packersdk.Artifact, bool, bool, error) {
@@ -293,6 +405,21 @@ func (p *PostProcessor) configureSingle(c *Config, raws ...interface{}) error {
 	}
 
 	var errs *packersdk.MultiError
+	if err := ValidateCompression(c.Format, c.CompressionLevel); err != nil {
+		errs = packersdk.MultiErrorAppend(errs, err)
+	}
+	for _, pattern := range c.Exclude {
+		if _, err := path.Match(pattern, ""); err != nil {
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf(
+				"Invalid exclude pattern %q: %s", pattern, err))
+		}
+	}
+	if c.Reproducible {
+		if _, err := SourceDateEpoch(); err != nil {
+			errs = packersdk.MultiErrorAppend(errs, err)
+		}
+	}
+
 	if c.VagrantfileTemplate != "" && c.VagrantfileTemplateGenerated == false {
 		_, err := os.Stat(c.VagrantfileTemplate)
 		if err != nil {
@@ -315,10 +442,39 @@ func (p *PostProcessor) specificConfig(name string) (Config, error) {
 			err = fmt.Errorf("Error overriding config for %s: %s", name, err)
 			return config, err
 		}
+		if err := ValidateCompression(config.Format, config.CompressionLevel); err != nil {
+			return config, fmt.Errorf("Error overriding config for %s: %s", name, err)
+		}
 	}
 	return config, nil
 }
 
+// overrides tells whether the configuration of a provider overrides a key.
+func (c *Config) overrides(name, key string) bool {
+	override, ok := c.Override[name].(map[string]interface{})
+	if !ok {
+		return false
+	}
+	_, ok = override[key]
+	return ok
+}
+
+func (c *Config) boxOptions() (BoxOptions, error) {
+	opts := BoxOptions{
+		Format:           c.Format,
+		CompressionLevel: c.CompressionLevel,
+		Reproducible:     c.Reproducible,
+	}
+	if c.Reproducible {
+		modTime, err := SourceDateEpoch()
+		if err != nil {
+			return opts, err
+		}
+		opts.ModTime = modTime
+	}
+	return opts, nil
+}
+
 func providerForName(name string) Provider {
 	switch name {
 	case "aws":
@@ -337,6 +493,8 @@ func providerForName(name string) Provider {
 		return new(HypervProvider)
 	case "libvirt":
 		return new(LibVirtProvider)
+	case "qemu":
+		return new(QemuProvider)
 	case "google":
 		return new(GoogleProvider)
 	case "lxc":
@@ -347,6 +505,10 @@ func providerForName(name string) Provider {
 		return new(DockerProvider)
 	case "file":
 		return new(FileProvider)
+	case "vsphere":
+		return new(VSphereProvider)
+	case "proxmox":
+		return new(ProxmoxProvider)
 	default:
 		return nil
 	}
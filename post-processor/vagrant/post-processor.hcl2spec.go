@@ -19,13 +19,17 @@ type FlatConfig struct {
 	PackerUserVars               map[string]string      `mapstructure:"packer_user_variables" cty:"packer_user_variables" hcl:"packer_user_variables"`
 	PackerSensitiveVars          []string               `mapstructure:"packer_sensitive_variables" cty:"packer_sensitive_variables" hcl:"packer_sensitive_variables"`
 	CompressionLevel             *int                   `mapstructure:"compression_level" cty:"compression_level" hcl:"compression_level"`
+	Format                       *string                `mapstructure:"format" cty:"format" hcl:"format"`
+	Reproducible                 *bool                  `mapstructure:"reproducible" cty:"reproducible" hcl:"reproducible"`
 	Include                      []string               `mapstructure:"include" cty:"include" hcl:"include"`
+	Exclude                      []string               `mapstructure:"exclude" cty:"exclude" hcl:"exclude"`
 	OutputPath                   *string                `mapstructure:"output" cty:"output" hcl:"output"`
 	Override                     map[string]interface{} `cty:"override" hcl:"override"`
 	VagrantfileTemplate          *string                `mapstructure:"vagrantfile_template" cty:"vagrantfile_template" hcl:"vagrantfile_template"`
 	VagrantfileTemplateGenerated *bool                  `mapstructure:"vagrantfile_template_generated" cty:"vagrantfile_template_generated" hcl:"vagrantfile_template_generated"`
 	ProviderOverride             *string                `mapstructure:"provider_override" cty:"provider_override" hcl:"provider_override"`
 	Architecture                 *string                `mapstructure:"architecture" cty:"architecture" hcl:"architecture"`
+	HasSSH                       *bool                  `mapstructure:"has_ssh" cty:"has_ssh" hcl:"has_ssh"`
 }
 
 // FlatMapstructure returns a new FlatConfig.
@@ -49,13 +53,17 @@ func (*FlatConfig) HCL2Spec() map[string]hcldec.Spec {
 		"packer_user_variables":          &hcldec.AttrSpec{Name: "packer_user_variables", Type: cty.Map(cty.String), Required: false},
 		"packer_sensitive_variables":     &hcldec.AttrSpec{Name: "packer_sensitive_variables", Type: cty.List(cty.String), Required: false},
 		"compression_level":              &hcldec.AttrSpec{Name: "compression_level", Type: cty.Number, Required: false},
+		"format":                         &hcldec.AttrSpec{Name: "format", Type: cty.String, Required: false},
+		"reproducible":                   &hcldec.AttrSpec{Name: "reproducible", Type: cty.Bool, Required: false},
 		"include":                        &hcldec.AttrSpec{Name: "include", Type: cty.List(cty.String), Required: false},
+		"exclude":                        &hcldec.AttrSpec{Name: "exclude", Type: cty.List(cty.String), Required: false},
 		"output":                         &hcldec.AttrSpec{Name: "output", Type: cty.String, Required: false},
 		"override":                       &hcldec.AttrSpec{Name: "override", Type: cty.Map(cty.String), Required: false},
 		"vagrantfile_template":           &hcldec.AttrSpec{Name: "vagrantfile_template", Type: cty.String, Required: false},
 		"vagrantfile_template_generated": &hcldec.AttrSpec{Name: "vagrantfile_template_generated", Type: cty.Bool, Required: false},
 		"provider_override":              &hcldec.AttrSpec{Name: "provider_override", Type: cty.String, Required: false},
 		"architecture":                   &hcldec.AttrSpec{Name: "architecture", Type: cty.String, Required: false},
+		"has_ssh":                        &hcldec.AttrSpec{Name: "has_ssh", Type: cty.Bool, Required: false},
 	}
 	return s
 }
@@ -8,12 +8,15 @@ import (
 	"compress/flate"
 	"context"
 	"io/ioutil"
+	"net"
 	"os"
+	"path/filepath"
 	"runtime"
 	"strings"
 	"testing"
 
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/rpc"
 )
 
 func testConfig() map[string]interface{} {
@@ -33,6 +36,7 @@ func testUi() *packersdk.BasicUi {
 	return &packersdk.BasicUi{
 		Reader: new(bytes.Buffer),
 		Writer: new(bytes.Buffer),
+		PB:     &packersdk.NoopProgressTracker{},
 	}
 }
 
@@ -68,6 +72,60 @@ func TestPostProcessorPrepare_compressionLevel(t *testing.T) {
 	}
 }
 
+func TestPostProcessorPrepare_format(t *testing.T) {
+	cases := []struct {
+		format      string
+		level       interface{}
+		errExpected bool
+	}{
+		{"", 9, false},
+		{"tar", 0, false},
+		{"tar.gz", 10, true},
+		{"tar.xz", 9, false},
+		{"tar.zst", 19, false},
+		{"tar.zst", 0, false},
+		{"tar.zst", -2, true},
+		{"tar.zst", 23, true},
+		{"zip", -1, false},
+		{"zip", -2, true},
+		{"rar", 1, true},
+	}
+	for _, tc := range cases {
+		var p PostProcessor
+		c := testConfig()
+		c["format"] = tc.format
+		c["compression_level"] = tc.level
+		err := p.Configure(c)
+		if (err != nil) != tc.errExpected {
+			t.Fatalf("format %q with level %v: unexpected result %v", tc.format, tc.level, err)
+		}
+	}
+}
+
+func TestPostProcessorPrepare_reproducible(t *testing.T) {
+	c := testConfig()
+	c["reproducible"] = true
+
+	t.Setenv("SOURCE_DATE_EPOCH", "1700000000")
+	var p PostProcessor
+	if err := p.Configure(c); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	opts, err := p.config.boxOptions()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !opts.Reproducible || opts.ModTime.Unix() != 1700000000 {
+		t.Fatalf("unexpected box options: %+v", opts)
+	}
+
+	t.Setenv("SOURCE_DATE_EPOCH", "yesterday")
+	p = PostProcessor{}
+	if err := p.Configure(c); err == nil {
+		t.Fatal("should have error")
+	}
+}
+
 func TestPostProcessorPrepare_architecture(t *testing.T) {
 	var p PostProcessor
 
@@ -102,6 +160,52 @@ func TestPostProcessorPrepare_architecture(t *testing.T) {
 
 }
 
+func TestPostProcessorPostProcess_detectedArchitecture(t *testing.T) {
+	dir := t.TempDir()
+	vmx := filepath.Join(dir, "packer.vmx")
+	if err := os.WriteFile(vmx, []byte("guestOS = \"arm-ubuntu-64\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		desc     string
+		config   map[string]interface{}
+		expected string
+	}{
+		{"unset", map[string]interface{}{}, "arm64"},
+		{"set", map[string]interface{}{"architecture": "amd64"}, "amd64"},
+		{"overridden", map[string]interface{}{
+			"override": map[string]interface{}{
+				"vmware": map[string]interface{}{"architecture": "i386"},
+			},
+		}, "i386"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			tc.config["output"] = filepath.Join(dir, "{{ .Architecture }}.box")
+			var p PostProcessor
+			if err := p.Configure(tc.config); err != nil {
+				t.Fatalf("err: %s", err)
+			}
+
+			artifact := &packersdk.MockArtifact{
+				BuilderIdValue: "mitchellh.vmware",
+				FilesValue:     []string{vmx},
+			}
+			result, _, _, err := p.PostProcess(context.Background(), testUi(), artifact)
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			if arch := result.State("architecture"); arch != tc.expected {
+				t.Fatalf("expected %s, got %v", tc.expected, arch)
+			}
+			if _, err := os.Stat(filepath.Join(dir, tc.expected+".box")); err != nil {
+				t.Fatalf("the box should be named after its architecture: %s", err)
+			}
+		})
+	}
+}
+
 func TestPostProcessorPrepare_outputPath(t *testing.T) {
 	var p PostProcessor
 
@@ -231,6 +335,138 @@ func TestPostProcessorPostProcess_badId(t *testing.T) {
 	}
 }
 
+func TestPostProcessorPostProcess_state(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "disk.img")
+	if err := os.WriteFile(input, []byte("disk"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var p PostProcessor
+	err := p.Configure(map[string]interface{}{
+		"architecture": "arm64",
+		"output":       filepath.Join(dir, "package.box"),
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	generatedData := map[interface{}]interface{}{"SourceImage": "base"}
+	artifact := &packersdk.MockArtifact{
+		BuilderIdValue: "packer.file",
+		IdValue:        "file",
+		FilesValue:     []string{input},
+		StateValues:    map[string]interface{}{"generated_data": generatedData},
+	}
+
+	box, _, _, err := p.PostProcess(context.Background(), testUi(), artifact)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer box.Destroy()
+
+	// The state is read the way the next post-processor sees it
+	result := rpcArtifact(t, box)
+
+	expectedMetadata := `{"architecture":"arm64","provider":"file"}`
+	if metadata := result.State("metadata"); metadata != expectedMetadata {
+		t.Fatalf("unexpected metadata: %#v", metadata)
+	}
+	if arch := result.State("architecture"); arch != "arm64" {
+		t.Fatalf("unexpected architecture: %#v", arch)
+	}
+	if provider := result.State("provider"); provider != "file" {
+		t.Fatalf("unexpected provider: %#v", provider)
+	}
+	info, err := os.Stat(filepath.Join(dir, "package.box"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := result.State("size"); size != info.Size() {
+		t.Fatalf("unexpected size: %#v", size)
+	}
+	// Maps come back as map[interface{}]interface{} over RPC
+	if digests, ok := result.State("digests").(map[interface{}]interface{}); !ok || digests["sha256"] == "" {
+		t.Fatalf("digests should be set: %#v", result.State("digests"))
+	}
+	data, ok := result.State("generated_data").(map[interface{}]interface{})
+	if !ok || data["SourceImage"] != "base" || data["Provider"] != "file" {
+		t.Fatalf("generated data should be passed on: %#v", result.State("generated_data"))
+	}
+	if files := result.Files(); len(files) != 2 {
+		t.Fatalf("the artifact should still be usable: %#v", files)
+	}
+}
+
+// rpcArtifact serves the artifact over the plugin RPC, and returns the client
+// side of it.
+func rpcArtifact(t *testing.T, artifact packersdk.Artifact) packersdk.Artifact {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		server, err := rpc.NewServer(conn)
+		if err != nil {
+			return
+		}
+		server.RegisterArtifact(artifact)
+		server.Serve()
+	}()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := rpc.NewClient(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client.Artifact()
+}
+
+func TestPostProcessorPostProcess_includedMetadata(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "metadata.json")
+	if err := os.WriteFile(input, []byte(`{"provider": "custom", "architecture": "i386"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var p PostProcessor
+	err := p.Configure(map[string]interface{}{
+		"output": filepath.Join(dir, "package.box"),
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	artifact := &packersdk.MockArtifact{
+		BuilderIdValue: "packer.file",
+		IdValue:        "file",
+		FilesValue:     []string{input},
+	}
+
+	result, _, _, err := p.PostProcess(context.Background(), testUi(), artifact)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer result.Destroy()
+
+	if arch := result.State("architecture"); arch != "i386" {
+		t.Fatalf("architecture should come from metadata.json: %#v", arch)
+	}
+	if provider := result.State("provider"); provider != "custom" {
+		t.Fatalf("provider should come from metadata.json: %#v", provider)
+	}
+}
+
 func TestProviderForName(t *testing.T) {
 	if v, ok := providerForName("virtualbox").(*VBoxProvider); !ok {
 		t.Fatalf("bad: %#v", v)
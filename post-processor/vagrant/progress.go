@@ -0,0 +1,150 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package vagrant
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// boxProgress follows how much of the files going into a box got written,
+// and how big the box is so far, to report the progress of writing it
+// through the progress bar of the UI along with the compression ratio. All
+// its methods do nothing on a nil boxProgress.
+type boxProgress struct {
+	ui    packersdk.Ui
+	total int64
+	start time.Time
+
+	// in is how much of the files was written, holes included, and out the
+	// size of the box
+	in  int64
+	out int64
+
+	// The progress bar is moved along by reading from tracker, which
+	// returns as many bytes as were added to source.
+	source  *progressSource
+	tracker io.ReadCloser
+}
+
+func newBoxProgress(ui packersdk.Ui, name string, total int64) *boxProgress {
+	source := &progressSource{}
+	return &boxProgress{
+		ui:      ui,
+		total:   total,
+		start:   time.Now(),
+		source:  source,
+		tracker: ui.TrackProgress(name, 0, total, source),
+	}
+}
+
+// Write counts the bytes written to the box.
+func (p *boxProgress) Write(b []byte) (int, error) {
+	if p != nil {
+		p.out += int64(len(b))
+	}
+	return len(b), nil
+}
+
+// advance records that n more bytes of the files were written.
+func (p *boxProgress) advance(n int64) {
+	if p == nil || n <= 0 {
+		return
+	}
+	p.in += n
+	p.source.n += n
+	_, _ = io.CopyN(io.Discard, p.tracker, n)
+}
+
+// reader counts the bytes read from r as written.
+func (p *boxProgress) reader(r io.Reader) io.Reader {
+	if p == nil {
+		return r
+	}
+	return &progressReader{r: r, progress: p}
+}
+
+// fileDone reports the compression ratio reached once a file is written.
+func (p *boxProgress) fileDone(name string) {
+	if p == nil {
+		return
+	}
+	p.ui.Message(fmt.Sprintf("Compressed: %s (ratio so far: %s)", name, p.ratio()))
+}
+
+// done closes the progress bar, and reports the final size of the box,
+// compression ratio and how long it took to write.
+func (p *boxProgress) done() {
+	if p == nil {
+		return
+	}
+	p.close()
+	p.ui.Message(fmt.Sprintf("Compressed %s into %s (ratio: %s) in %s",
+		formatBytes(p.in), formatBytes(p.out), p.ratio(),
+		time.Since(p.start).Round(time.Second)))
+}
+
+// close closes the progress bar, if it wasn't already.
+func (p *boxProgress) close() {
+	if p == nil || p.tracker == nil {
+		return
+	}
+	p.tracker.Close()
+	p.tracker = nil
+}
+
+// ratio is how many times smaller the box is than its files.
+func (p *boxProgress) ratio() string {
+	if p.out == 0 {
+		return "n/a"
+	}
+	return fmt.Sprintf("%.2f", float64(p.in)/float64(p.out))
+}
+
+type progressReader struct {
+	r        io.Reader
+	progress *boxProgress
+}
+
+func (r *progressReader) Read(b []byte) (int, error) {
+	n, err := r.r.Read(b)
+	r.progress.advance(int64(n))
+	return n, err
+}
+
+// progressSource is read from by the progress tracker of the UI, as many
+// bytes as the files written since the last read.
+type progressSource struct {
+	n int64
+}
+
+func (s *progressSource) Read(b []byte) (int, error) {
+	if s.n == 0 {
+		return 0, io.EOF
+	}
+	n := int(min(int64(len(b)), s.n))
+	s.n -= int64(n)
+	return n, nil
+}
+
+func (s *progressSource) Close() error {
+	return nil
+}
+
+// formatBytes formats a size with binary units.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
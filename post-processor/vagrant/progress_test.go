@@ -0,0 +1,92 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package vagrant
+
+import (
+	"bytes"
+	"compress/flate"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// progressUi records the progress reported to it.
+type progressUi struct {
+	packersdk.BasicUi
+	total   int64
+	tracked int64
+	closed  bool
+}
+
+func (u *progressUi) TrackProgress(_ string, _, total int64, stream io.ReadCloser) io.ReadCloser {
+	u.total = total
+	return u
+}
+
+func (u *progressUi) Read(b []byte) (int, error) {
+	u.tracked += int64(len(b))
+	return len(b), nil
+}
+
+func (u *progressUi) Close() error {
+	u.closed = true
+	return nil
+}
+
+func TestDirToBox_progress(t *testing.T) {
+	for _, format := range []string{FormatTarGz, FormatZip} {
+		t.Run(format, func(t *testing.T) {
+			dir := t.TempDir()
+			disk := filepath.Join(dir, "disk.img")
+			writeSparseFile(t, disk)
+			files := []BoxFile{
+				{Name: "disk.img", Path: disk},
+				{Name: "metadata.json", Open: func() (io.ReadCloser, error) {
+					return io.NopCloser(strings.NewReader(`{"provider": "file"}`)), nil
+				}, Size: 20},
+			}
+
+			output := new(bytes.Buffer)
+			ui := &progressUi{BasicUi: packersdk.BasicUi{Reader: new(bytes.Buffer), Writer: output}}
+			opts := BoxOptions{Format: format, CompressionLevel: flate.DefaultCompression}
+			if _, _, err := DirToBox(filepath.Join(dir, "package.box"), t.TempDir(), files, ui, opts); err != nil {
+				t.Fatalf("err: %s", err)
+			}
+
+			expected := int64(16<<20 + 20)
+			if ui.total != expected || ui.tracked != expected {
+				t.Fatalf("expected progress up to %d, got %d of %d", expected, ui.tracked, ui.total)
+			}
+			if !ui.closed {
+				t.Fatal("the progress bar should be closed")
+			}
+			if !strings.Contains(output.String(), "Compressed: disk.img (ratio so far:") {
+				t.Fatalf("the ratio should be reported after each file:\n%s", output)
+			}
+			if !strings.Contains(output.String(), "Compressed 16.0 MiB into") {
+				t.Fatalf("the final ratio should be reported:\n%s", output)
+			}
+		})
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	cases := map[int64]string{
+		0:         "0 B",
+		1023:      "1023 B",
+		1024:      "1.0 KiB",
+		1536:      "1.5 KiB",
+		40 << 30:  "40.0 GiB",
+		5 << 40:   "5.0 TiB",
+		123 << 20: "123.0 MiB",
+	}
+	for n, expected := range cases {
+		if s := formatBytes(n); s != expected {
+			t.Errorf("%d: expected %q, got %q", n, expected, s)
+		}
+	}
+}
@@ -4,6 +4,9 @@
 package vagrant
 
 import (
+	"io"
+	"os"
+
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 )
 
@@ -19,7 +22,50 @@ type Provider interface {
 	// put things.
 	//
 	// The Provider should return the contents for the Vagrantfile,
-	// any metadata (including the provider type in that), and an error
-	// if any.
-	Process(packersdk.Ui, packersdk.Artifact, string) (vagrantfile string, metadata map[string]interface{}, err error)
+	// any metadata (including the provider type in that), the files to add
+	// to the box and an error if any. Everything written to the temporary
+	// directory ends up in the box too, but large files from the artifact
+	// should be returned as BoxFiles instead of being copied there, so they
+	// are streamed straight into the box.
+	Process(packersdk.Ui, packersdk.Artifact, string) (vagrantfile string, metadata map[string]interface{}, files []BoxFile, err error)
+}
+
+// ArchitectureDetector is implemented by the providers that can tell the
+// architecture of the guest from the artifact, which is then used for the
+// box unless the architecture is set in the configuration.
+type ArchitectureDetector interface {
+	// DetectArchitecture returns the architecture of the guest, in the
+	// naming of Vagrant, or an empty string when the artifact doesn't tell.
+	DetectArchitecture(packersdk.Artifact) (string, error)
+}
+
+// ArchitectureSetter is implemented by the providers whose Vagrantfile
+// depends on the architecture of the box, which is set before Process.
+type ArchitectureSetter interface {
+	SetArchitecture(string)
+}
+
+// SSHSetter is implemented by the providers that can run guests without an
+// SSH server, whose Vagrantfile depends on whether the guest runs one, which
+// is set before Process.
+type SSHSetter interface {
+	SetHasSSH(bool)
+}
+
+// BoxFile is a file to add to the box without copying it to the temporary
+// directory first.
+type BoxFile struct {
+	// Name of the file in the box, relative to its root.
+	Name string
+	// Path to the file to add.
+	Path string
+	// Open returns the contents of the file, for files that don't exist on
+	// their own on disk, like the ones inside of an OVA. When set, it is used
+	// instead of Path, and Size must be set as well.
+	Open func() (io.ReadCloser, error)
+	// Size of the contents returned by Open.
+	Size int64
+	// Mode of the file in the box. Defaults to the mode of the file at Path,
+	// or 0644 when using Open.
+	Mode os.FileMode
 }
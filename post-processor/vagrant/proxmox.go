@@ -0,0 +1,72 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package vagrant
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"text/template"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+type proxmoxVagrantfileTemplate struct {
+	Template string
+	Node     string
+}
+
+// ProxmoxProvider makes pointer boxes for vagrant-proxmox, which clone the
+// template the proxmox builders made.
+type ProxmoxProvider struct{}
+
+func (p *ProxmoxProvider) KeepInputArtifact() bool {
+	return true
+}
+
+func (p *ProxmoxProvider) Process(ui packersdk.Ui, artifact packersdk.Artifact, dir string) (vagrantfile string, metadata map[string]interface{}, files []BoxFile, err error) {
+	// Create the metadata
+	metadata = map[string]interface{}{"provider": "proxmox"}
+
+	// The ID of the artifact is the VMID of the template
+	vmid := artifact.Id()
+	if _, err = strconv.ParseUint(vmid, 10, 32); err != nil {
+		err = fmt.Errorf("Poorly formatted artifact ID, expected the VMID of a template: %s", vmid)
+		return
+	}
+
+	tplData := &proxmoxVagrantfileTemplate{
+		Template: artifactString(artifact, "template_name", "TemplateName"),
+		Node:     artifactString(artifact, "node", "Node"),
+	}
+	// vagrant-proxmox finds the template to clone by its name only
+	if tplData.Template == "" {
+		err = fmt.Errorf("The name of the Proxmox template %s is unknown: the artifact sets neither "+
+			"its template_name state nor its TemplateName generated data", vmid)
+		return
+	}
+	metadata["vmid"] = vmid
+	if tplData.Node != "" {
+		metadata["node"] = tplData.Node
+	}
+
+	// Build up the Vagrantfile
+	var contents bytes.Buffer
+	t := template.Must(template.New("vf").Parse(defaultProxmoxVagrantfile))
+	err = t.Execute(&contents, tplData)
+	vagrantfile = contents.String()
+	return
+}
+
+var defaultProxmoxVagrantfile = `
+Vagrant.configure("2") do |config|
+  config.vm.provider :proxmox do |proxmox|
+    proxmox.vm_type = :qemu
+    proxmox.qemu_template = "{{ .Template }}"
+    {{- if .Node }}
+    proxmox.selected_node = "{{ .Node }}"
+    {{- end }}
+  end
+end
+`
@@ -0,0 +1,70 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package vagrant
+
+import (
+	"strings"
+	"testing"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func TestProxmoxProvider_impl(t *testing.T) {
+	var _ Provider = new(ProxmoxProvider)
+}
+
+func TestProxmoxProvider_KeepInputArtifact(t *testing.T) {
+	p := new(ProxmoxProvider)
+
+	if !p.KeepInputArtifact() {
+		t.Fatal("should keep input artifact")
+	}
+}
+
+func TestProxmoxProvider_ArtifactId(t *testing.T) {
+	p := new(ProxmoxProvider)
+	ui := testUi()
+	artifact := &packersdk.MockArtifact{
+		BuilderIdValue: "proxmox.iso",
+		IdValue:        "9000",
+		StateValues: map[string]interface{}{
+			"generated_data": map[interface{}]interface{}{
+				"TemplateName": "debian-12",
+				"Node":         "pve1",
+			},
+		},
+	}
+
+	vagrantfile, metadata, _, err := p.Process(ui, artifact, "foo")
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	for _, result := range []string{
+		`proxmox.vm_type = :qemu`,
+		`proxmox.qemu_template = "debian-12"`,
+		`proxmox.selected_node = "pve1"`,
+	} {
+		if !strings.Contains(vagrantfile, result) {
+			t.Fatalf("missing %s: %s", result, vagrantfile)
+		}
+	}
+	if metadata["vmid"] != "9000" || metadata["node"] != "pve1" {
+		t.Fatalf("bad metadata: %#v", metadata)
+	}
+}
+
+func TestProxmoxProvider_NoTemplateName(t *testing.T) {
+	artifact := &packersdk.MockArtifact{BuilderIdValue: "proxmox.iso", IdValue: "9000"}
+	_, _, _, err := new(ProxmoxProvider).Process(testUi(), artifact, "foo")
+	if err == nil || !strings.Contains(err.Error(), "template 9000 is unknown") {
+		t.Fatalf("a template without a name should be an error, got %v", err)
+	}
+}
+
+func TestProxmoxProvider_BadArtifactId(t *testing.T) {
+	artifact := &packersdk.MockArtifact{IdValue: "us-east-1:ami-1234"}
+	if _, _, _, err := new(ProxmoxProvider).Process(testUi(), artifact, "foo"); err == nil {
+		t.Fatal("should have error")
+	}
+}
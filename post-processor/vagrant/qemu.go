@@ -0,0 +1,119 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package vagrant
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// QemuProvider makes boxes for vagrant-qemu, which runs QEMU directly rather
+// than through libvirt. It is only selected with provider_override, as the
+// artifacts of QEMU go to libvirt by default.
+type QemuProvider struct {
+	// architecture of the box, which QEMU emulates
+	architecture string
+}
+
+func (p *QemuProvider) KeepInputArtifact() bool {
+	return false
+}
+
+func (p *QemuProvider) SetArchitecture(arch string) {
+	p.architecture = arch
+}
+
+func (p *QemuProvider) Process(ui packersdk.Ui, artifact packersdk.Artifact, dir string) (vagrantfile string, metadata map[string]interface{}, files []BoxFile, err error) {
+	state := make(map[string]string)
+	for _, key := range []string{"diskType", "diskName"} {
+		if state[key], err = stateString(artifact, key, true); err != nil {
+			return
+		}
+	}
+	if state["diskSize"], err = stateString(artifact, "diskSize", false); err != nil {
+		return
+	}
+
+	if state["diskType"] != "qcow2" {
+		err = fmt.Errorf("vagrant-qemu boxes need a qcow2 disk, the artifact has a %s one", state["diskType"])
+		return
+	}
+
+	// vagrant-qemu boxes have the layout of the first format of
+	// vagrant-libvirt, with a single disk named box.img
+	var disks []string
+	for _, path := range artifact.Files() {
+		if strings.HasPrefix(filepath.Base(path), state["diskName"]) && filepath.Ext(path) != ".fd" {
+			disks = append(disks, path)
+		}
+	}
+	switch len(disks) {
+	case 0:
+		err = fmt.Errorf("No disk named %s found in the artifact", state["diskName"])
+		return
+	case 1:
+	default:
+		err = fmt.Errorf("vagrant-qemu boxes hold a single disk, the artifact has %d", len(disks))
+		return
+	}
+	ui.Message(fmt.Sprintf("Adding from artifact: %s", disks[0]))
+	files = append(files, BoxFile{Name: "box.img", Path: disks[0]})
+
+	// Create the metadata
+	metadata = map[string]interface{}{
+		"provider": "qemu",
+		"format":   "qcow2",
+	}
+	if state["diskSize"] != "" {
+		var size uint64
+		if size, err = sizeInMegabytes(state["diskSize"]); err != nil {
+			err = fmt.Errorf("Error reading the disk size of the artifact: %s", err)
+			return
+		}
+		metadata["virtual_size"] = (size + 1023) / 1024
+	}
+
+	// The defaults of vagrant-qemu are for arm64 guests, the machine, CPU
+	// and network device of x86 guests are set along with their architecture
+	var settings []string
+	if arch := qemuArchitectureNames[p.architecture]; arch != "" {
+		settings = append(settings, fmt.Sprintf("qe.arch = %q", arch))
+	}
+	switch p.architecture {
+	case "amd64", "i386":
+		settings = append(settings, `qe.machine = "q35"`, `qe.cpu = "max"`, `qe.net_device = "virtio-net-pci"`)
+	}
+
+	vagrantfile = fmt.Sprintf(qemuVagrantfile, strings.Join(settings, "\n    "))
+	return
+}
+
+// DetectArchitecture tells the architecture of the guest as for libvirt.
+func (p *QemuProvider) DetectArchitecture(artifact packersdk.Artifact) (string, error) {
+	return new(LibVirtProvider).DetectArchitecture(artifact)
+}
+
+// qemuArchitectureNames are the names QEMU gives to the architectures of
+// Vagrant.
+var qemuArchitectureNames = map[string]string{
+	"amd64":   "x86_64",
+	"i386":    "i386",
+	"arm64":   "aarch64",
+	"arm":     "arm",
+	"ppc64le": "ppc64",
+	"ppc64":   "ppc64",
+	"s390x":   "s390x",
+	"riscv64": "riscv64",
+}
+
+var qemuVagrantfile = `
+Vagrant.configure("2") do |config|
+  config.vm.provider "qemu" do |qe|
+    %s
+  end
+end
+`
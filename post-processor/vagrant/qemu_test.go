@@ -0,0 +1,119 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package vagrant
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func TestQemuProvider_impl(t *testing.T) {
+	var _ Provider = new(QemuProvider)
+}
+
+func TestQemuProvider_Process(t *testing.T) {
+	dir := t.TempDir()
+	var artifactFiles []string
+	for _, name := range []string{"packer-debian", "efivars.fd"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		artifactFiles = append(artifactFiles, path)
+	}
+	artifact := &packersdk.MockArtifact{
+		BuilderIdValue: "transcend.qemu",
+		FilesValue:     artifactFiles,
+		StateValues: map[string]interface{}{
+			"diskType":   "qcow2",
+			"diskSize":   "20G",
+			"diskName":   "packer-debian",
+			"domainType": "hvf",
+		},
+	}
+
+	p := new(QemuProvider)
+	p.SetArchitecture("arm64")
+	vagrantfile, metadata, files, err := p.Process(testUi(), artifact, t.TempDir())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if len(files) != 1 || files[0].Name != "box.img" || files[0].Path != artifactFiles[0] {
+		t.Errorf("bad files: %#v", files)
+	}
+	if metadata["provider"] != "qemu" || metadata["format"] != "qcow2" || metadata["virtual_size"] != uint64(20) {
+		t.Errorf("bad metadata: %#v", metadata)
+	}
+	if !strings.Contains(vagrantfile, `qe.arch = "aarch64"`) || strings.Contains(vagrantfile, "qe.machine") {
+		t.Errorf("bad Vagrantfile:\n%s", vagrantfile)
+	}
+
+	// x86 guests don't run with the defaults of vagrant-qemu
+	p.SetArchitecture("amd64")
+	vagrantfile, _, _, err = p.Process(testUi(), artifact, t.TempDir())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	for _, setting := range []string{
+		`config.vm.provider "qemu" do |qe|`,
+		`qe.arch = "x86_64"`,
+		`qe.machine = "q35"`,
+		`qe.cpu = "max"`,
+		`qe.net_device = "virtio-net-pci"`,
+	} {
+		if !strings.Contains(vagrantfile, setting) {
+			t.Errorf("Vagrantfile is missing %s:\n%s", setting, vagrantfile)
+		}
+	}
+}
+
+func TestQemuProvider_Process_errors(t *testing.T) {
+	dir := t.TempDir()
+	var artifactFiles []string
+	for _, name := range []string{"packer", "packer-1"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		artifactFiles = append(artifactFiles, path)
+	}
+
+	cases := map[string]*packersdk.MockArtifact{
+		"missing state": {FilesValue: artifactFiles[:1]},
+		"raw disk": {
+			FilesValue:  artifactFiles[:1],
+			StateValues: map[string]interface{}{"diskType": "raw", "diskName": "packer"},
+		},
+		"several disks": {
+			FilesValue:  artifactFiles,
+			StateValues: map[string]interface{}{"diskType": "qcow2", "diskName": "packer"},
+		},
+		"no disk": {
+			FilesValue:  []string{},
+			StateValues: map[string]interface{}{"diskType": "qcow2", "diskName": "packer"},
+		},
+	}
+	for desc, artifact := range cases {
+		if _, _, _, err := new(QemuProvider).Process(testUi(), artifact, t.TempDir()); err == nil {
+			t.Errorf("%s: expected an error", desc)
+		}
+	}
+}
+
+func TestPostProcessorPrepare_qemuProviderOverride(t *testing.T) {
+	var p PostProcessor
+	c := testConfig()
+	c["provider_override"] = "qemu"
+	if err := p.Configure(c); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, ok := providerForName("qemu").(*QemuProvider); !ok {
+		t.Fatal("qemu should be a provider")
+	}
+}
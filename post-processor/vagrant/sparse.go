@@ -0,0 +1,305 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package vagrant
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+)
+
+// Disk images are scanned for holes, and extracted, in blocks of this size.
+const sparseBlockSize = 4096
+
+// tarBlockSize is the size of the blocks a tar archive is made of.
+const tarBlockSize = 512
+
+var zeroBlock = make([]byte, sparseBlockSize)
+
+var errSeekDataUnsupported = errors.New("SEEK_DATA is not supported")
+
+// sparseEntry is a region of a file holding data.
+type sparseEntry struct {
+	Offset int64
+	Length int64
+}
+
+// sparseData returns the regions of f holding data, leaving out its holes
+// and blocks of zeros. Only the regions SEEK_DATA reports, when the platform
+// and file system support it, are read to look for blocks of zeros, so that
+// fully allocated images are made sparse too while holes are never read.
+// The regions are aligned to blocks, which makes the result only depend on
+// the contents of f, as reproducible boxes need. The data regions are read
+// again to be written, as the map of a sparse entry comes before its data.
+func sparseData(f *os.File, size int64) ([]sparseEntry, error) {
+	regions, err := seekData(f, size)
+	if _, serr := f.Seek(0, io.SeekStart); serr != nil {
+		return nil, serr
+	}
+	if err != nil {
+		regions = []sparseEntry{{Offset: 0, Length: size}}
+	}
+	return scanData(f, alignData(regions, size))
+}
+
+// alignData extends the regions to whole blocks, merging those that then
+// overlap or touch.
+func alignData(regions []sparseEntry, size int64) []sparseEntry {
+	var aligned []sparseEntry
+	for _, r := range regions {
+		start := r.Offset - r.Offset%sparseBlockSize
+		end := r.Offset + r.Length
+		if rem := end % sparseBlockSize; rem != 0 {
+			end = min(end+sparseBlockSize-rem, size)
+		}
+		if last := len(aligned) - 1; last >= 0 && aligned[last].Offset+aligned[last].Length >= start {
+			aligned[last].Length = max(aligned[last].Length, end-aligned[last].Offset)
+			continue
+		}
+		aligned = append(aligned, sparseEntry{Offset: start, Length: end - start})
+	}
+	return aligned
+}
+
+// scanData returns the parts of the regions of f that aren't only zeros.
+// The regions must start at a block.
+func scanData(f *os.File, regions []sparseEntry) ([]sparseEntry, error) {
+	var data []sparseEntry
+	buf := make([]byte, 256*sparseBlockSize)
+	for _, r := range regions {
+		end := r.Offset + r.Length
+		for offset := r.Offset; offset < end; {
+			chunk := buf[:min(int64(len(buf)), end-offset)]
+			n, err := f.ReadAt(chunk, offset)
+			if n < len(chunk) {
+				if err == nil || err == io.EOF {
+					err = io.ErrUnexpectedEOF
+				}
+				return nil, err
+			}
+
+			for i := 0; i < n; i += sparseBlockSize {
+				block := chunk[i:min(i+sparseBlockSize, n)]
+				if bytes.Equal(block, zeroBlock[:len(block)]) {
+					continue
+				}
+				start := offset + int64(i)
+				if last := len(data) - 1; last >= 0 && data[last].Offset+data[last].Length == start {
+					data[last].Length += int64(len(block))
+				} else {
+					data = append(data, sparseEntry{Offset: start, Length: int64(len(block))})
+				}
+			}
+			offset += int64(n)
+		}
+	}
+	return data, nil
+}
+
+// isSparse tells whether the data regions leave any hole in the file.
+func isSparse(data []sparseEntry, size int64) bool {
+	var length int64
+	for _, d := range data {
+		length += d.Length
+	}
+	return length < size
+}
+
+// addSparse writes a file with holes as a PAX sparse entry, in the 1.0
+// format of GNU tar, which archive/tar reads but cannot write. Only the
+// data regions of the file are stored, after a map of where they go.
+func (a *tarArchive) addSparse(header *tar.Header, f *os.File, data []sparseEntry) error {
+	// The entry is written straight to the output, so the padding of the
+	// previous one has to be written first
+	if err := a.writer.Flush(); err != nil {
+		return err
+	}
+
+	// GNU tar ends the map with an empty region when the file ends with a
+	// hole
+	if len(data) == 0 || data[len(data)-1].Offset+data[len(data)-1].Length < header.Size {
+		data = append(data, sparseEntry{Offset: header.Size})
+	}
+
+	var sparseMap bytes.Buffer
+	fmt.Fprintf(&sparseMap, "%d\n", len(data))
+	var length int64
+	for _, d := range data {
+		fmt.Fprintf(&sparseMap, "%d\n%d\n", d.Offset, d.Length)
+		length += d.Length
+	}
+	sparseMap.Write(make([]byte, tarPadding(int64(sparseMap.Len()))))
+	size := int64(sparseMap.Len()) + length
+
+	records := paxRecord("GNU.sparse.major", "1") +
+		paxRecord("GNU.sparse.minor", "0") +
+		paxRecord("GNU.sparse.name", header.Name) +
+		paxRecord("GNU.sparse.realsize", strconv.FormatInt(header.Size, 10))
+	fileName := path.Join(path.Dir(header.Name), "GNUSparseFile.0", path.Base(header.Name))
+	fileHeader, overflows := ustarHeader(fileName, tar.TypeReg, size, header)
+	for _, key := range overflows {
+		switch key {
+		case "path":
+			records += paxRecord(key, fileName)
+		case "uname":
+			records += paxRecord(key, header.Uname)
+		case "gname":
+			records += paxRecord(key, header.Gname)
+		case "size":
+			records += paxRecord(key, strconv.FormatInt(size, 10))
+		case "uid":
+			records += paxRecord(key, strconv.Itoa(header.Uid))
+		case "gid":
+			records += paxRecord(key, strconv.Itoa(header.Gid))
+		case "mtime":
+			records += paxRecord(key, strconv.FormatInt(header.ModTime.Unix(), 10))
+		}
+	}
+	// Readers ignore the name of the PAX header itself, and its records
+	// can't overflow, so it is fine for its fields to be cut
+	paxHeader, _ := ustarHeader(
+		path.Join(path.Dir(header.Name), "PaxHeaders.0", path.Base(header.Name)),
+		tar.TypeXHeader, int64(len(records)), header)
+
+	for _, b := range [][]byte{
+		paxHeader,
+		[]byte(records),
+		make([]byte, tarPadding(int64(len(records)))),
+		fileHeader,
+		sparseMap.Bytes(),
+	} {
+		if _, err := a.out.Write(b); err != nil {
+			return err
+		}
+	}
+	for _, d := range data {
+		section := a.progress.reader(io.NewSectionReader(f, d.Offset, d.Length))
+		if _, err := io.CopyN(a.out, section, d.Length); err != nil {
+			return err
+		}
+	}
+	// The holes are written as well, by being left out
+	a.progress.advance(header.Size - length)
+	_, err := a.out.Write(make([]byte, tarPadding(size)))
+	return err
+}
+
+// ustarHeader encodes a single header block, for the given name, type and
+// size, taking the other fields from header. It returns the PAX keys of the
+// fields that don't fit, which are cut or zeroed in the block, and have to
+// be written as PAX records.
+func ustarHeader(name string, typeflag byte, size int64, header *tar.Header) ([]byte, []string) {
+	var overflows []string
+	b := make([]byte, tarBlockSize)
+	putOctal := func(field []byte, key string, value int64) {
+		digits := strconv.FormatInt(value, 8)
+		if value < 0 || len(digits) > len(field)-1 {
+			overflows = append(overflows, key)
+			digits = "0"
+		}
+		for i := 0; i < len(field)-1-len(digits); i++ {
+			field[i] = '0'
+		}
+		copy(field[len(field)-1-len(digits):], digits)
+	}
+
+	putString := func(field []byte, key string, value string) {
+		if len(value) > len(field) {
+			overflows = append(overflows, key)
+		}
+		copy(field, value)
+	}
+
+	putString(b[0:100], "path", name)
+	putOctal(b[100:108], "mode", header.Mode&07777)
+	putOctal(b[108:116], "uid", int64(header.Uid))
+	putOctal(b[116:124], "gid", int64(header.Gid))
+	putOctal(b[124:136], "size", size)
+	putOctal(b[136:148], "mtime", header.ModTime.Unix())
+	b[156] = typeflag
+	copy(b[257:265], "ustar\x0000")
+	putString(b[265:297], "uname", header.Uname)
+	putString(b[297:329], "gname", header.Gname)
+
+	// The checksum is computed as if its own field was made of spaces
+	copy(b[148:156], "        ")
+	var sum int64
+	for _, c := range b {
+		sum += int64(c)
+	}
+	putOctal(b[148:155], "", sum)
+	return b, overflows
+}
+
+// paxRecord formats a PAX record, which starts with its own length.
+func paxRecord(key, value string) string {
+	// Space, equals sign and newline
+	size := len(key) + len(value) + 3
+	size += len(strconv.Itoa(size))
+	record := strconv.Itoa(size) + " " + key + "=" + value + "\n"
+	// Adding the length may have made it one digit longer
+	if len(record) != size {
+		size = len(record)
+		record = strconv.Itoa(size) + " " + key + "=" + value + "\n"
+	}
+	return record
+}
+
+func tarPadding(size int64) int64 {
+	return -size & (tarBlockSize - 1)
+}
+
+// copySparse copies r to the start of f, seeking over blocks of zeros rather
+// than writing them, so that sparse entries of archives are extracted back
+// into sparse files.
+func copySparse(f *os.File, r io.Reader) (int64, error) {
+	buf := make([]byte, 256*sparseBlockSize)
+	var written int64
+	for {
+		n, err := io.ReadFull(r, buf)
+
+		// Write runs of data blocks at once
+		start := -1
+		for i := 0; i < n; i += sparseBlockSize {
+			block := buf[i:min(i+sparseBlockSize, n)]
+			if !bytes.Equal(block, zeroBlock[:len(block)]) {
+				if start < 0 {
+					start = i
+				}
+				continue
+			}
+			if start >= 0 {
+				if _, werr := f.Write(buf[start:i]); werr != nil {
+					return written, werr
+				}
+				start = -1
+			}
+			if _, serr := f.Seek(int64(len(block)), io.SeekCurrent); serr != nil {
+				return written, serr
+			}
+		}
+		if start >= 0 {
+			if _, werr := f.Write(buf[start:n]); werr != nil {
+				return written, werr
+			}
+		}
+		written += int64(n)
+
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return written, err
+		}
+	}
+
+	// Seeking past the end doesn't make the file any bigger, so a trailing
+	// hole needs the size to be set
+	return written, f.Truncate(written)
+}
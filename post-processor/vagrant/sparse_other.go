@@ -0,0 +1,14 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build !linux && !darwin && !freebsd
+
+package vagrant
+
+import "os"
+
+// seekData is not available on this platform, so holes are found by scanning
+// files instead.
+func seekData(f *os.File, size int64) ([]sparseEntry, error) {
+	return nil, errSeekDataUnsupported
+}
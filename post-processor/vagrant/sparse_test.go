@@ -0,0 +1,231 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package vagrant
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/flate"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeSparseFile writes a 16MiB file with data at its start and middle, and
+// a hole at the end.
+func writeSparseFile(t *testing.T, path string) []byte {
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if err := f.Truncate(16 << 20); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteAt([]byte("boot sector"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteAt(bytes.Repeat([]byte("data"), 3000), 8<<20); err != nil {
+		t.Fatal(err)
+	}
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return contents
+}
+
+func TestDirToBox_sparse(t *testing.T) {
+	dir := t.TempDir()
+	disk := filepath.Join(dir, "box-disk1.img")
+	expected := writeSparseFile(t, disk)
+	// The same contents, with the zeros written out
+	allocated := filepath.Join(dir, "box-disk2.img")
+	if err := os.WriteFile(allocated, expected, 0644); err != nil {
+		t.Fatal(err)
+	}
+	metadata := map[string]interface{}{
+		"provider": "libvirt",
+		"disks":    []map[string]string{{"path": "disks/box-disk1.img"}, {"path": "disks/box-disk2.img"}},
+	}
+	if err := WriteMetadata(dir, metadata); err != nil {
+		t.Fatal(err)
+	}
+	boxDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(boxDir, "Vagrantfile"), []byte("Vagrant.configure(\"2\") do |config|\nend\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, reproducible := range []bool{false, true} {
+		box := filepath.Join(t.TempDir(), "package.box")
+		files := []BoxFile{
+			{Name: "disks/box-disk1.img", Path: disk},
+			{Name: "disks/box-disk2.img", Path: allocated},
+			{Name: "metadata.json", Path: filepath.Join(dir, "metadata.json")},
+		}
+		opts := BoxOptions{Format: FormatTar, CompressionLevel: flate.NoCompression, Reproducible: reproducible}
+		_, manifest, err := DirToBox(box, boxDir, files, nil, opts)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if err := ValidateBox(box, manifest); err != nil {
+			t.Fatalf("the sparse box should be valid: %s", err)
+		}
+
+		info, err := os.Stat(box)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size() > 1<<20 {
+			t.Fatalf("holes and zeros should have been left out of the box, got %d bytes", info.Size())
+		}
+
+		f, err := os.Open(box)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		var names []string
+		tr := tar.NewReader(f)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			names = append(names, hdr.Name)
+			if !strings.HasPrefix(hdr.Name, "disks/") {
+				continue
+			}
+			contents, err := io.ReadAll(tr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if hdr.Size != int64(len(expected)) || !bytes.Equal(contents, expected) {
+				t.Fatalf("sparse file %s wasn't restored, got %d bytes", hdr.Name, len(contents))
+			}
+		}
+		if strings.Join(names, ",") != "Vagrantfile,disks/box-disk1.img,disks/box-disk2.img,metadata.json" {
+			t.Fatalf("unexpected box contents: %v", names)
+		}
+	}
+}
+
+func TestDirToBox_sparseLongName(t *testing.T) {
+	disk := filepath.Join(t.TempDir(), "disk.img")
+	expected := writeSparseFile(t, disk)
+	name := strings.Repeat("directory/", 12) + "disk.img"
+
+	box := filepath.Join(t.TempDir(), "package.box")
+	files := []BoxFile{{Name: name, Path: disk}}
+	opts := BoxOptions{Format: FormatTar, CompressionLevel: flate.NoCompression}
+	if _, _, err := DirToBox(box, t.TempDir(), files, nil, opts); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	f, err := os.Open(box)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	tr := tar.NewReader(f)
+	hdr, err := tr.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hdr.Name != name {
+		t.Fatalf("the name of the entry was cut: %s", hdr.Name)
+	}
+	contents, err := io.ReadAll(tr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(contents, expected) {
+		t.Fatalf("sparse file wasn't restored, got %d bytes", len(contents))
+	}
+}
+
+func TestUstarHeader_overflows(t *testing.T) {
+	header := &tar.Header{Uname: strings.Repeat("u", 33), Gname: "staff", ModTime: time.Unix(0, 0)}
+	_, overflows := ustarHeader(strings.Repeat("a", 101), tar.TypeReg, 1<<34, header)
+	if strings.Join(overflows, ",") != "path,size,uname" {
+		t.Fatalf("unexpected overflows: %v", overflows)
+	}
+}
+
+func TestAlignData(t *testing.T) {
+	cases := []struct {
+		regions  []sparseEntry
+		size     int64
+		expected []sparseEntry
+	}{
+		{
+			regions:  []sparseEntry{{Offset: 512, Length: 512}},
+			size:     16384,
+			expected: []sparseEntry{{Offset: 0, Length: 4096}},
+		},
+		{
+			regions:  []sparseEntry{{Offset: 0, Length: 1024}, {Offset: 3072, Length: 2048}},
+			size:     16384,
+			expected: []sparseEntry{{Offset: 0, Length: 8192}},
+		},
+		{
+			regions:  []sparseEntry{{Offset: 0, Length: 512}, {Offset: 8192, Length: 1000}},
+			size:     9192,
+			expected: []sparseEntry{{Offset: 0, Length: 4096}, {Offset: 8192, Length: 1000}},
+		},
+	}
+	for _, tc := range cases {
+		if actual := alignData(tc.regions, tc.size); !reflect.DeepEqual(actual, tc.expected) {
+			t.Fatalf("%v: expected %v, got %v", tc.regions, tc.expected, actual)
+		}
+	}
+}
+
+func TestCopySparse(t *testing.T) {
+	expected := writeSparseFile(t, filepath.Join(t.TempDir(), "disk.img"))
+
+	path := filepath.Join(t.TempDir(), "extracted.img")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := copySparse(f, bytes.NewReader(expected))
+	f.Close()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if n != int64(len(expected)) {
+		t.Fatalf("expected %d bytes to be copied, got %d", len(expected), n)
+	}
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(contents, expected) {
+		t.Fatalf("extracted file differs from the original")
+	}
+}
+
+func TestPaxRecord(t *testing.T) {
+	cases := map[string][2]string{
+		"19 path=/etc/hosts\n": {"path", "/etc/hosts"},
+		"6 a=b\n":              {"a", "b"},
+		"11 a=names\n":         {"a", "names"},
+	}
+	for expected, record := range cases {
+		if actual := paxRecord(record[0], record[1]); actual != expected {
+			t.Fatalf("expected %q, got %q", expected, actual)
+		}
+	}
+}
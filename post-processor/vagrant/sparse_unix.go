@@ -0,0 +1,37 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build linux || darwin || freebsd
+
+package vagrant
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// seekData returns the regions of f holding data, as reported by the file
+// system.
+func seekData(f *os.File, size int64) ([]sparseEntry, error) {
+	fd := int(f.Fd())
+	var data []sparseEntry
+	for offset := int64(0); offset < size; {
+		start, err := unix.Seek(fd, offset, unix.SEEK_DATA)
+		if err == unix.ENXIO {
+			// Only a hole is left
+			break
+		}
+		if err != nil {
+			return nil, errSeekDataUnsupported
+		}
+		end, err := unix.Seek(fd, start, unix.SEEK_HOLE)
+		if err != nil {
+			return nil, errSeekDataUnsupported
+		}
+		end = min(end, size)
+		data = append(data, sparseEntry{Offset: start, Length: end - start})
+		offset = end
+	}
+	return data, nil
+}
@@ -4,169 +4,250 @@
 package vagrant
 
 import (
-	"archive/tar"
-	"compress/flate"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"hash"
 	"io"
 	"log"
 	"os"
 	"path/filepath"
 	"runtime"
+	"sort"
+	"time"
 
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 	"github.com/hashicorp/packer-plugin-sdk/tmp"
 	"github.com/klauspost/pgzip"
 )
 
-var (
-	// ErrInvalidCompressionLevel is returned when the compression level passed
-	// to gzip is not in the expected range. See compress/flate for details.
-	ErrInvalidCompressionLevel = fmt.Errorf(
-		"Invalid compression level. Expected an integer from -1 to 9.")
-)
+// DirToBox takes the directory, along with the given files, and compresses
+// it into a Vagrant-compatible box, as set in the options. Files are
+// streamed straight from where they are; when one has the same name as a
+// file in the directory, the former wins. The digests of the box are
+// computed while it is written, and returned hex encoded, keyed by
+// algorithm, along with the manifest of the files written, for ValidateBox
+// to check the box against. This function does not perform checks to
+// verify that dir is actually a proper box. This is an expected
+// precondition.
+func DirToBox(dst, dir string, files []BoxFile, ui packersdk.Ui, opts BoxOptions) (map[string]string, *BoxManifest, error) {
+	log.Printf("Turning dir into box: %s => %s", dir, dst)
 
-// Copies a file by copying the contents of the file to another place.
-func CopyContents(dst, src string) error {
-	srcF, err := os.Open(src)
+	// Make the containing directory, if it does not already exist
+	err := os.MkdirAll(filepath.Dir(dst), 0755)
 	if err != nil {
-		return err
-	}
-	defer srcF.Close()
-
-	dstDir, _ := filepath.Split(dst)
-	if dstDir != "" {
-		err := os.MkdirAll(dstDir, 0755)
-		if err != nil {
-			return err
-		}
+		return nil, nil, err
 	}
 
 	dstF, err := os.Create(dst)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 	defer dstF.Close()
 
-	if _, err := io.Copy(dstF, srcF); err != nil {
-		return err
+	hashes := map[string]hash.Hash{
+		"sha256": sha256.New(),
+		"sha512": sha512.New(),
+	}
+	// Later files replace earlier ones of the same name
+	files = dedupeBoxFiles(files)
+	names := make(map[string]bool, len(files))
+	for _, f := range files {
+		names[filepath.Clean(f.Name)] = true
 	}
 
-	return nil
-}
+	// This is the walk func that collects each of the files in the dir
+	var entries []BoxFile
+	tarWalk := func(path string, info os.FileInfo, prevErr error) error {
+		// If there was a prior error, return it
+		if prevErr != nil {
+			return prevErr
+		}
+
+		// Skip directories
+		if info.IsDir() {
+			log.Printf("Skipping directory '%s' for box '%s'", path, dst)
+			return nil
+		}
 
-// Creates a (hard) link to a file, ensuring that all parent directories also exist.
-func LinkFile(dst, src string) error {
-	dstDir, _ := filepath.Split(dst)
-	if dstDir != "" {
-		err := os.MkdirAll(dstDir, 0755)
+		name, err := filepath.Rel(dir, path)
 		if err != nil {
 			return err
 		}
-	}
+		if names[name] {
+			log.Printf("Skipping '%s' for box '%s', replaced by an artifact file", path, dst)
+			return nil
+		}
 
-	if err := os.Link(src, dst); err != nil {
-		return err
+		entries = append(entries, BoxFile{Name: name, Path: path})
+		return nil
 	}
 
-	return nil
-}
-
-// DirToBox takes the directory and compresses it into a Vagrant-compatible
-// box. This function does not perform checks to verify that dir is
-// actually a proper box. This is an expected precondition.
-func DirToBox(dst, dir string, ui packersdk.Ui, level int) error {
-	log.Printf("Turning dir into box: %s => %s", dir, dst)
-
-	// Make the containing directory, if it does not already exist
-	err := os.MkdirAll(filepath.Dir(dst), 0755)
+	// Archive everything up
+	err = filepath.Walk(dir, tarWalk)
+	entries = append(entries, files...)
+	if opts.Reproducible {
+		sort.SliceStable(entries, func(i, j int) bool {
+			return filepath.ToSlash(filepath.Clean(entries[i].Name)) < filepath.ToSlash(filepath.Clean(entries[j].Name))
+		})
+	}
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
-	dstF, err := os.Create(dst)
+	var progress *boxProgress
+	writers := []io.Writer{dstF, hashes["sha256"], hashes["sha512"]}
+	if ui != nil {
+		var total int64
+		for _, f := range entries {
+			total += boxFileSize(f)
+		}
+		progress = newBoxProgress(ui, filepath.Base(dst), total)
+		defer progress.close()
+		writers = append(writers, progress)
+	}
+
+	opts.Format = BoxFormat(opts.Format, opts.CompressionLevel)
+	log.Printf("Writing box as %s with compression level: %d", opts.Format, opts.CompressionLevel)
+	archive, err := newBoxArchive(io.MultiWriter(writers...), opts, progress)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
-	defer dstF.Close()
 
-	var dstWriter io.WriteCloser = dstF
-	if level != flate.NoCompression {
-		log.Printf("Compressing with gzip compression level: %d", level)
-		gzipWriter, err := makePgzipWriter(dstWriter, level)
-		if err != nil {
-			return err
+	manifest := newBoxManifest(opts.Format)
+	for i := 0; err == nil && i < len(entries); i++ {
+		err = addBoxFile(archive, entries[i], ui, manifest)
+		if err == nil {
+			progress.fileDone(entries[i].Name)
 		}
-		defer gzipWriter.Close()
+	}
+	if err != nil {
+		archive.Close()
+		return nil, nil, err
+	}
+	if err := archive.Close(); err != nil {
+		return nil, nil, err
+	}
+	if err := dstF.Close(); err != nil {
+		return nil, nil, err
+	}
+	progress.done()
 
-		dstWriter = gzipWriter
+	digests := make(map[string]string, len(hashes))
+	for algorithm, h := range hashes {
+		digests[algorithm] = hex.EncodeToString(h.Sum(nil))
 	}
+	return digests, manifest, nil
+}
 
-	tarWriter := tar.NewWriter(dstWriter)
-	defer tarWriter.Close()
+// WriteChecksumFile writes the digest of the box next to it, in the format
+// of sha256sum, and returns the path of that file.
+func WriteChecksumFile(box, sha256Digest string) (string, error) {
+	path := box + ".sha256"
+	contents := fmt.Sprintf("%s  %s\n", sha256Digest, filepath.Base(box))
+	return path, os.WriteFile(path, []byte(contents), 0644)
+}
 
-	// This is the walk func that tars each of the files in the dir
-	tarWalk := func(path string, info os.FileInfo, prevErr error) error {
-		// If there was a prior error, return it
-		if prevErr != nil {
-			return prevErr
-		}
+// boxFileSize returns the size of a file going into a box, or 0 if it can't
+// be read, which adding it to the box reports.
+func boxFileSize(f BoxFile) int64 {
+	if f.Open != nil {
+		return f.Size
+	}
+	info, err := os.Stat(f.Path)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
 
-		// Skip directories
-		if info.IsDir() {
-			log.Printf("Skipping directory '%s' for box '%s'", path, dst)
-			return nil
+// dedupeBoxFiles drops the files that are replaced by a later file of the
+// same name, keeping the order of the others.
+func dedupeBoxFiles(files []BoxFile) []BoxFile {
+	last := make(map[string]int, len(files))
+	for i, f := range files {
+		last[filepath.Clean(f.Name)] = i
+	}
+	deduped := make([]BoxFile, 0, len(last))
+	for i, f := range files {
+		if last[filepath.Clean(f.Name)] == i {
+			deduped = append(deduped, f)
 		}
+	}
+	return deduped
+}
 
-		log.Printf("Box add: '%s' to '%s'", path, dst)
-		f, err := os.Open(path)
+func addBoxFile(archive boxArchive, f BoxFile, ui packersdk.Ui, manifest *BoxManifest) error {
+	var r io.ReadCloser
+	var info os.FileInfo
+	var err error
+	if f.Open != nil {
+		log.Printf("Box add: '%s'", f.Name)
+		r, err = f.Open()
 		if err != nil {
 			return err
 		}
-		defer f.Close()
-
-		header, err := tar.FileInfoHeader(info, "")
+		info = &boxFileInfo{name: filepath.Base(f.Name), size: f.Size, mode: f.Mode}
+	} else {
+		log.Printf("Box add: '%s' as '%s'", f.Path, f.Name)
+		var file *os.File
+		file, err = os.Open(f.Path)
 		if err != nil {
 			return err
 		}
-
-		// go >=1.10 wants to use GNU tar format to workaround issues in
-		// libarchive < 3.3.2
-		setHeaderFormat(header)
-
-		// We have to set the Name explicitly because it is supposed to
-		// be a relative path to the root. Otherwise, the tar ends up
-		// being a bunch of files in the root, even if they're actually
-		// nested in a dir in the original "dir" param.
-		header.Name, err = filepath.Rel(dir, path)
+		r = file
+		info, err = file.Stat()
 		if err != nil {
+			file.Close()
 			return err
 		}
-
-		if ui != nil {
-			ui.Message(fmt.Sprintf("Compressing: %s", header.Name))
+		if f.Mode != 0 {
+			info = &boxFileInfo{name: info.Name(), size: info.Size(), mode: f.Mode, modTime: info.ModTime()}
 		}
+	}
+	defer r.Close()
 
-		if err := tarWriter.WriteHeader(header); err != nil {
-			return err
-		}
+	if ui != nil {
+		ui.Message(fmt.Sprintf("Compressing: %s", f.Name))
+	}
 
-		if _, err := io.Copy(tarWriter, f); err != nil {
-			return err
-		}
+	name := filepath.Clean(f.Name)
+	manifest.Files[filepath.ToSlash(name)] = info.Size()
+	return archive.Add(name, info, r)
+}
 
-		return nil
-	}
+// boxFileInfo describes a BoxFile that doesn't exist on disk as is.
+type boxFileInfo struct {
+	name    string
+	size    int64
+	mode    os.FileMode
+	modTime time.Time
+}
 
-	// Tar.gz everything up
-	return filepath.Walk(dir, tarWalk)
+func (i *boxFileInfo) Name() string { return i.name }
+func (i *boxFileInfo) Size() int64  { return i.size }
+func (i *boxFileInfo) Mode() os.FileMode {
+	if i.mode == 0 {
+		return 0644
+	}
+	return i.mode
 }
+func (i *boxFileInfo) ModTime() time.Time {
+	if i.modTime.IsZero() {
+		return time.Now()
+	}
+	return i.modTime
+}
+func (i *boxFileInfo) IsDir() bool      { return false }
+func (i *boxFileInfo) Sys() interface{} { return nil }
 
 // CreateDummyBox create a dummy Vagrant-compatible box under temporary dir
 // This function is mainly used to check cases such as the host system having
 // a GNU tar incompatible uname that will cause the actual Vagrant box creation
 // to fail later
-func CreateDummyBox(ui packersdk.Ui, level int) error {
+func CreateDummyBox(ui packersdk.Ui, opts BoxOptions) error {
 	ui.Say("Creating a dummy Vagrant box to ensure the host system can create one correctly")
 
 	// Create a temporary dir to create dummy Vagrant box from
@@ -188,7 +269,7 @@ func CreateDummyBox(ui packersdk.Ui, level int) error {
 	}
 	defer tempBox.Close()
 	defer os.Remove(tempBox.Name())
-	if err := DirToBox(tempBox.Name(), tempDir, nil, level); err != nil {
+	if _, _, err := DirToBox(tempBox.Name(), tempDir, nil, nil, opts); err != nil {
 		return err
 	}
 
@@ -211,10 +292,31 @@ func WriteMetadata(dir string, contents interface{}) error {
 	return nil
 }
 
-func makePgzipWriter(output io.WriteCloser, compressionLevel int) (io.WriteCloser, error) {
+// readMetadata decodes a metadata.json file that is added to the box as is.
+func readMetadata(f BoxFile) (map[string]interface{}, error) {
+	var r io.ReadCloser
+	var err error
+	if f.Open != nil {
+		r, err = f.Open()
+	} else {
+		r, err = os.Open(f.Path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var metadata map[string]interface{}
+	if err := json.NewDecoder(r).Decode(&metadata); err != nil {
+		return nil, err
+	}
+	return metadata, nil
+}
+
+func makePgzipWriter(output io.Writer, compressionLevel int) (*pgzip.Writer, error) {
 	gzipWriter, err := pgzip.NewWriterLevel(output, compressionLevel)
 	if err != nil {
-		return nil, ErrInvalidCompressionLevel
+		return nil, ValidateCompression(FormatTarGz, compressionLevel)
 	}
 	_ = gzipWriter.SetConcurrency(500000, runtime.GOMAXPROCS(-1))
 	return gzipWriter, nil
@@ -0,0 +1,237 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package vagrant
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/klauspost/pgzip"
+	"github.com/ulikunitz/xz"
+)
+
+// Files of the box read by the validation, which are all small.
+var validatedFiles = map[string]bool{"metadata.json": true, "box.ovf": true}
+
+// ovfReferences lists the files an OVF refers to.
+type ovfReferences struct {
+	Files []struct {
+		ID   string `xml:"id,attr"`
+		Href string `xml:"href,attr"`
+	} `xml:"References>File"`
+}
+
+// BoxManifest lists the files written to a box, with their size, for
+// ValidateBox to check the box against.
+type BoxManifest struct {
+	// Format is the format the box is written in.
+	Format string
+	Files  map[string]int64
+}
+
+func newBoxManifest(format string) *BoxManifest {
+	return &BoxManifest{Format: format, Files: make(map[string]int64)}
+}
+
+// ValidateBox reads the box back, checking that it holds the files of the
+// manifest, whole, and that they are the ones Vagrant needs to use it with
+// the provider set in its metadata.json.
+func ValidateBox(box string, manifest *BoxManifest) error {
+	limits := defaultExtractLimits
+	limits.MaxEntries = max(limits.MaxEntries, len(manifest.Files))
+
+	files := make(map[string]bool, len(manifest.Files))
+	contents := make(map[string][]byte)
+	err := walkBox(box, manifest.Format, limits, func(name string, r io.Reader) error {
+		size, ok := manifest.Files[name]
+		if !ok || files[name] {
+			return fmt.Errorf("%s is in the box, but wasn't written to it", name)
+		}
+		files[name] = true
+
+		var data []byte
+		var err error
+		if validatedFiles[name] {
+			if data, err = io.ReadAll(io.LimitReader(r, 16<<20)); err != nil {
+				return fmt.Errorf("Error reading %s from the box: %s", name, err)
+			}
+			contents[name] = data
+		}
+		n, err := io.Copy(io.Discard, r)
+		if err != nil {
+			return fmt.Errorf("Error reading %s from the box: %s", name, err)
+		}
+		if n += int64(len(data)); n != size {
+			return fmt.Errorf("%s holds %d bytes in the box, instead of %d", name, n, size)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, name := range slices.Sorted(maps.Keys(manifest.Files)) {
+		if !files[name] {
+			return fmt.Errorf("%s was written to the box, but is missing from it", name)
+		}
+	}
+
+	if !files["Vagrantfile"] {
+		return errors.New("Vagrantfile is missing from the box")
+	}
+	if !files["metadata.json"] {
+		return errors.New("metadata.json is missing from the box")
+	}
+	var metadata map[string]interface{}
+	if err := json.Unmarshal(contents["metadata.json"], &metadata); err != nil {
+		return fmt.Errorf("metadata.json of the box is invalid: %s", err)
+	}
+	provider, _ := metadata["provider"].(string)
+	if provider == "" {
+		return errors.New("metadata.json of the box doesn't set a provider")
+	}
+
+	switch provider {
+	case "virtualbox":
+		if !files["box.ovf"] {
+			return errors.New("box.ovf is missing from the virtualbox box")
+		}
+		var ovf ovfReferences
+		if err := xml.Unmarshal(contents["box.ovf"], &ovf); err != nil {
+			return fmt.Errorf("box.ovf of the box is invalid: %s", err)
+		}
+		if len(ovf.Files) == 0 {
+			return errors.New("box.ovf of the box doesn't reference any disk")
+		}
+		for _, f := range ovf.Files {
+			if !files[path.Clean(f.Href)] {
+				return fmt.Errorf("%s, referenced by box.ovf, is missing from the box", f.Href)
+			}
+		}
+	case "vmware_desktop":
+		if !hasBoxFile(files, func(name string) bool { return path.Ext(name) == ".vmx" }) {
+			return errors.New("No .vmx file in the vmware_desktop box")
+		}
+	case "libvirt":
+		disks, _ := metadata["disks"].([]interface{})
+		if len(disks) == 0 {
+			// Boxes in the first format of the provider have a single disk
+			if _, ok := metadata["format"]; ok && files["box.img"] {
+				return nil
+			}
+			return errors.New("metadata.json of the libvirt box doesn't list any disk")
+		}
+		for i, disk := range disks {
+			diskMap, _ := disk.(map[string]interface{})
+			diskPath, _ := diskMap["path"].(string)
+			if diskPath == "" {
+				return fmt.Errorf("Disk %d in metadata.json of the libvirt box has no path", i)
+			}
+			if !files[path.Clean(diskPath)] {
+				return fmt.Errorf("%s, listed in metadata.json, is missing from the libvirt box", diskPath)
+			}
+		}
+	case "qemu":
+		if !files["box.img"] {
+			return errors.New("No box.img disk in the qemu box")
+		}
+	case "parallels":
+		if !hasBoxFile(files, func(name string) bool {
+			dir := strings.SplitN(name, "/", 2)[0]
+			return path.Ext(dir) == ".pvm" || path.Ext(dir) == ".macvm"
+		}) {
+			return errors.New("No .pvm or .macvm VM in the parallels box")
+		}
+	}
+	return nil
+}
+
+func hasBoxFile(files map[string]bool, match func(string) bool) bool {
+	for name := range files {
+		if match(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// walkBox calls fn with each file of a box written in the given format,
+// checking the entries against the limits.
+func walkBox(box, format string, limits extractLimits, fn func(name string, r io.Reader) error) error {
+	if format == FormatZip {
+		zipReader, err := zip.OpenReader(box)
+		if err != nil {
+			return err
+		}
+		defer zipReader.Close()
+		if len(zipReader.File) > limits.MaxEntries {
+			return fmt.Errorf("Archive has more than %d entries", limits.MaxEntries)
+		}
+		for _, f := range zipReader.File {
+			name, err := archivePath(f.Name)
+			if err != nil {
+				return err
+			}
+			if !f.Mode().IsRegular() {
+				return fmt.Errorf("%s isn't a regular file in the box", name)
+			}
+			r, err := f.Open()
+			if err != nil {
+				return err
+			}
+			err = fn(name, r)
+			r.Close()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	f, err := os.Open(box)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	switch format {
+	case FormatTarGz:
+		gzipReader, err := pgzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gzipReader.Close()
+		r = gzipReader
+	case FormatTarXz:
+		if r, err = xz.NewReader(f); err != nil {
+			return err
+		}
+	case FormatTarZst:
+		zstdReader, err := zstd.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer zstdReader.Close()
+		r = zstdReader
+	}
+
+	tr := tar.NewReader(r)
+	return walkTar(tr, limits, func(name string, hdr *tar.Header) error {
+		if hdr.Typeflag != tar.TypeReg {
+			return fmt.Errorf("%s isn't a regular file in the box", name)
+		}
+		return fn(name, tr)
+	})
+}
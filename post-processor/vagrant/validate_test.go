@@ -0,0 +1,241 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package vagrant
+
+import (
+	"compress/flate"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateBox(t *testing.T) {
+	ovf := `<Envelope><References><File ovf:href="box-disk001.vmdk" ovf:id="file1"/></References></Envelope>`
+	cases := []struct {
+		desc  string
+		files map[string]string
+		err   string
+	}{
+		{
+			desc: "virtualbox",
+			files: map[string]string{
+				"metadata.json":    `{"provider": "virtualbox"}`,
+				"box.ovf":          ovf,
+				"box-disk001.vmdk": "disk",
+			},
+		},
+		{
+			desc: "virtualbox without ovf",
+			files: map[string]string{
+				"metadata.json":    `{"provider": "virtualbox"}`,
+				"box-disk001.vmdk": "disk",
+			},
+			err: "box.ovf is missing",
+		},
+		{
+			desc: "virtualbox without disk",
+			files: map[string]string{
+				"metadata.json": `{"provider": "virtualbox"}`,
+				"box.ovf":       ovf,
+			},
+			err: "box-disk001.vmdk, referenced by box.ovf, is missing",
+		},
+		{
+			desc: "vmware",
+			files: map[string]string{
+				"metadata.json": `{"provider": "vmware_desktop"}`,
+				"packer.vmx":    "vmx",
+			},
+		},
+		{
+			desc: "vmware without vmx",
+			files: map[string]string{
+				"metadata.json": `{"provider": "vmware_desktop"}`,
+				"disk.vmdk":     "disk",
+			},
+			err: "No .vmx file",
+		},
+		{
+			desc: "libvirt",
+			files: map[string]string{
+				"metadata.json": `{"provider": "libvirt", "disks": [{"path": "box_0.img", "format": "qcow2"}]}`,
+				"box_0.img":     "disk",
+			},
+		},
+		{
+			desc: "libvirt without disks",
+			files: map[string]string{
+				"metadata.json": `{"provider": "libvirt", "disks": []}`,
+			},
+			err: "doesn't list any disk",
+		},
+		{
+			desc: "libvirt with a missing disk",
+			files: map[string]string{
+				"metadata.json": `{"provider": "libvirt", "disks": [{"path": "box_0.img"}, {"path": "box_1.img"}]}`,
+				"box_0.img":     "disk",
+			},
+			err: "box_1.img, listed in metadata.json, is missing",
+		},
+		{
+			desc: "qemu",
+			files: map[string]string{
+				"metadata.json": `{"provider": "qemu", "format": "qcow2"}`,
+				"box.img":       "disk",
+			},
+		},
+		{
+			desc: "qemu without disk",
+			files: map[string]string{
+				"metadata.json": `{"provider": "qemu", "format": "qcow2"}`,
+			},
+			err: "No box.img disk",
+		},
+		{
+			desc: "parallels",
+			files: map[string]string{
+				"metadata.json":         `{"provider": "parallels"}`,
+				"packer.pvm/config.pvs": "config",
+			},
+		},
+		{
+			desc: "parallels without pvm",
+			files: map[string]string{
+				"metadata.json": `{"provider": "parallels"}`,
+			},
+			err: "No .pvm or .macvm VM",
+		},
+		{
+			desc:  "no metadata",
+			files: map[string]string{},
+			err:   "metadata.json is missing",
+		},
+		{
+			desc: "invalid metadata",
+			files: map[string]string{
+				"metadata.json": `{"provider": `,
+			},
+			err: "metadata.json of the box is invalid",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			dir := t.TempDir()
+			tc.files["Vagrantfile"] = "Vagrant.configure(\"2\") do |config|\nend\n"
+			for name, contents := range tc.files {
+				path := filepath.Join(dir, filepath.FromSlash(name))
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			for _, format := range []string{FormatTarGz, FormatZip} {
+				box := filepath.Join(t.TempDir(), "package.box")
+				opts := BoxOptions{Format: format, CompressionLevel: flate.DefaultCompression}
+				_, manifest, err := DirToBox(box, dir, nil, nil, opts)
+				if err != nil {
+					t.Fatalf("err: %s", err)
+				}
+
+				err = ValidateBox(box, manifest)
+				if tc.err == "" && err != nil {
+					t.Fatalf("%s: unexpected error: %s", format, err)
+				}
+				if tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)) {
+					t.Fatalf("%s: expected error %q, got %v", format, tc.err, err)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateBox_archive(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"Vagrantfile":   "Vagrant.configure(\"2\") do |config|\nend\n",
+		"metadata.json": `{"provider": "qemu", "format": "qcow2"}`,
+		"box.img":       strings.Repeat("disk", 1024),
+	}
+	for name, contents := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := []struct {
+		desc    string
+		modify  func(box string, manifest *BoxManifest)
+		invalid bool
+		err     string
+	}{
+		{
+			desc:   "valid",
+			modify: func(string, *BoxManifest) {},
+		},
+		{
+			desc: "truncated",
+			modify: func(box string, _ *BoxManifest) {
+				info, err := os.Stat(box)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := os.Truncate(box, info.Size()/2); err != nil {
+					t.Fatal(err)
+				}
+			},
+			invalid: true,
+		},
+		{
+			desc: "file of another size",
+			modify: func(_ string, manifest *BoxManifest) {
+				manifest.Files["box.img"]++
+			},
+			err: "box.img holds 4096 bytes in the box, instead of 4097",
+		},
+		{
+			desc: "missing file",
+			modify: func(_ string, manifest *BoxManifest) {
+				manifest.Files["box_1.img"] = 0
+			},
+			err: "box_1.img was written to the box, but is missing from it",
+		},
+		{
+			desc: "unexpected file",
+			modify: func(_ string, manifest *BoxManifest) {
+				delete(manifest.Files, "box.img")
+			},
+			err: "box.img is in the box, but wasn't written to it",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			for _, format := range []string{FormatTarGz, FormatZip} {
+				box := filepath.Join(t.TempDir(), "package.box")
+				opts := BoxOptions{Format: format, CompressionLevel: flate.DefaultCompression}
+				_, manifest, err := DirToBox(box, dir, nil, nil, opts)
+				if err != nil {
+					t.Fatalf("err: %s", err)
+				}
+				tc.modify(box, manifest)
+
+				err = ValidateBox(box, manifest)
+				if !tc.invalid && tc.err == "" && err != nil {
+					t.Fatalf("%s: unexpected error: %s", format, err)
+				}
+				if tc.invalid && err == nil {
+					t.Fatalf("%s: expected an error", format)
+				}
+				if tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)) {
+					t.Fatalf("%s: expected error %q, got %v", format, tc.err, err)
+				}
+			}
+		})
+	}
+}
@@ -5,14 +5,15 @@ package vagrant
 
 import (
 	"archive/tar"
+	"encoding/xml"
 	"errors"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"log"
 	"os"
+	"path"
 	"path/filepath"
-	"regexp"
+	"strings"
 
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 )
@@ -23,160 +24,316 @@ func (p *VBoxProvider) KeepInputArtifact() bool {
 	return false
 }
 
-func (p *VBoxProvider) Process(ui packersdk.Ui, artifact packersdk.Artifact, dir string) (vagrantfile string, metadata map[string]interface{}, err error) {
+func (p *VBoxProvider) Process(ui packersdk.Ui, artifact packersdk.Artifact, dir string) (vagrantfile string, metadata map[string]interface{}, files []BoxFile, err error) {
 	// Create the metadata
 	metadata = map[string]interface{}{"provider": "virtualbox"}
 
-	// Copy all of the original contents into the temporary directory
+	// Add all of the original contents to the box
 	for _, path := range artifact.Files() {
-		// We treat OVA files specially, we unpack those into the temporary
-		// directory so we can get the resulting disk and OVF.
+		// We treat OVA files specially, we unpack the OVF into the temporary
+		// directory and stream the disks from the OVA into the box.
 		if extension := filepath.Ext(path); extension == ".ova" {
-			ui.Message(fmt.Sprintf("Unpacking OVA: %s", path))
-			if err = DecompressOva(dir, path); err != nil {
+			ui.Message(fmt.Sprintf("Adding from OVA: %s", path))
+			var ovaFiles []BoxFile
+			ovaFiles, err = streamOva(path, func(name string, r io.Reader) error {
+				output, err := os.Create(filepath.Join(dir, name))
+				if err != nil {
+					return err
+				}
+				defer output.Close()
+				_, err = io.Copy(output, r)
+				return err
+			})
+			if err != nil {
 				return
 			}
+			files = append(files, ovaFiles...)
 		} else {
-			ui.Message(fmt.Sprintf("Copying from artifact: %s", path))
-			dstPath := filepath.Join(dir, filepath.Base(path))
-			if err = CopyContents(dstPath, path); err != nil {
-				return
-			}
+			ui.Message(fmt.Sprintf("Adding from artifact: %s", path))
+			files = append(files, BoxFile{Name: filepath.Base(path), Path: path})
 		}
 
 	}
 
+	// VMs exported as a .vbox file and their disks get an OVF written for
+	// them
+	var machine *vboxMachine
+	if files, machine, err = p.vboxToOvf(ui, dir, files); err != nil {
+		return
+	}
+
 	// Rename the OVF file to box.ovf, as required by Vagrant
 	ui.Message("Renaming the OVF to box.ovf...")
-	if err = p.renameOVF(dir); err != nil {
+	var ovf string
+	if ovf, err = p.renameOVF(dir, files); err != nil {
 		return
 	}
 
+	// The settings of the VM are in the .vbox file, or in the OVF
+	var hardware vboxHardware
+	if machine != nil {
+		// The OVF written for the .vbox file doesn't hold the settings of
+		// the VM, so VirtualBox names the controllers when importing it
+		hardware = machine.hardware()
+		hardware.DiskControllerName = ovfControllers[hardware.DiskController].Name
+	} else {
+		var envelope *ovfEnvelope
+		if envelope, err = readOvf(ovf); err != nil {
+			return
+		}
+		hardware = envelope.hardware()
+	}
+	for key, value := range hardware.metadata() {
+		metadata[key] = value
+	}
+
 	// Create the Vagrantfile from the template
-	var baseMacAddress string
-	baseMacAddress, err = p.findBaseMacAddress(dir)
-	if err != nil {
+	baseMacAddress := hardware.baseMacAddress()
+	if baseMacAddress == "" {
+		err = errors.New("can't find base mac address in OVF")
 		return
 	}
+	log.Printf("Base mac address: %s", baseMacAddress)
 
-	vagrantfile = fmt.Sprintf(vboxVagrantfile, baseMacAddress)
+	vagrantfile = fmt.Sprintf(vboxVagrantfile, baseMacAddress, hardware.vagrantfile())
 	return
 }
 
-func (p *VBoxProvider) findOvf(dir string) (string, error) {
+// vboxToOvf writes an OVF for VMs given as a .vbox file and their disks,
+// and leaves the .vbox file out of the box. It returns the settings of the
+// VM, or nil if there was no .vbox file, or an OVF already.
+func (p *VBoxProvider) vboxToOvf(ui packersdk.Ui, dir string, files []BoxFile) ([]BoxFile, *vboxMachine, error) {
+	ovfs, err := filepath.Glob(filepath.Join(dir, "*.ovf"))
+	if err != nil || len(ovfs) > 0 {
+		return files, nil, err
+	}
+	vbox := ""
+	var disks []BoxFile
+	for _, f := range files {
+		switch filepath.Ext(f.Name) {
+		case ".ovf":
+			return files, nil, nil
+		case ".vbox":
+			vbox = f.Path
+		case ".vbox-prev", ".vbox-tmp":
+		default:
+			disks = append(disks, f)
+		}
+	}
+	if vbox == "" {
+		return files, nil, nil
+	}
+
+	ui.Message(fmt.Sprintf("Writing an OVF for %s...", filepath.Base(vbox)))
+	machine, err := readVbox(vbox)
+	if err != nil {
+		return nil, nil, err
+	}
+	out, err := os.Create(filepath.Join(dir, "box.ovf"))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer out.Close()
+	if err := ovfFromVbox(out, machine, disks); err != nil {
+		return nil, nil, err
+	}
+	return disks, machine, out.Close()
+}
+
+// findOvf looks for the OVF, either unpacked into dir or among the files
+// of the artifact. It returns its path, and its index in files or -1.
+func (p *VBoxProvider) findOvf(dir string, files []BoxFile) (string, int, error) {
 	log.Println("Looking for OVF in artifact...")
 	file_matches, err := filepath.Glob(filepath.Join(dir, "*.ovf"))
 	if err != nil {
-		return "", err
+		return "", -1, err
+	}
+
+	index := -1
+	for i, f := range files {
+		if filepath.Ext(f.Name) == ".ovf" {
+			file_matches = append(file_matches, f.Path)
+			index = i
+		}
 	}
 
 	if len(file_matches) > 1 {
-		return "", errors.New("More than one OVF file in VirtualBox artifact.")
+		return "", -1, errors.New("More than one OVF file in VirtualBox artifact.")
 	}
 
 	if len(file_matches) < 1 {
-		return "", errors.New("ovf file couldn't be found")
+		return "", -1, errors.New("ovf file couldn't be found")
 	}
 
-	return file_matches[0], err
+	return file_matches[0], index, err
 }
 
-func (p *VBoxProvider) renameOVF(dir string) error {
+// renameOVF makes sure the OVF ends up as box.ovf in the box, and returns
+// the path it can be read from.
+func (p *VBoxProvider) renameOVF(dir string, files []BoxFile) (string, error) {
 	log.Println("Looking for OVF to rename...")
-	ovf, err := p.findOvf(dir)
+	ovf, index, err := p.findOvf(dir, files)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	log.Printf("Renaming: '%s' => box.ovf", ovf)
-	return os.Rename(ovf, filepath.Join(dir, "box.ovf"))
+	if index >= 0 {
+		files[index].Name = "box.ovf"
+		return ovf, nil
+	}
+	boxOvf := filepath.Join(dir, "box.ovf")
+	return boxOvf, os.Rename(ovf, boxOvf)
 }
 
-func (p *VBoxProvider) findBaseMacAddress(dir string) (string, error) {
-	log.Println("Looking for OVF for base mac address...")
-	ovf, err := p.findOvf(dir)
-	if err != nil {
-		return "", err
+// DetectArchitecture tells the architecture of the guest from the OS type of
+// the OVF of the artifact, which may be inside of an OVA, or of its .vbox
+// file.
+func (p *VBoxProvider) DetectArchitecture(artifact packersdk.Artifact) (string, error) {
+	var vbox string
+	for _, path := range artifact.Files() {
+		var data []byte
+		var err error
+		switch filepath.Ext(path) {
+		case ".ovf":
+			data, err = os.ReadFile(path)
+		case ".ova":
+			data, err = readOvaOvf(path)
+		case ".vbox":
+			vbox = path
+			continue
+		default:
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+
+		var envelope ovfEnvelope
+		if err := xml.Unmarshal(data, &envelope); err != nil {
+			return "", fmt.Errorf("Error reading OVF: %s", err)
+		}
+		osType := envelope.VirtualSystem.OperatingSystem.OSType
+		if osType == "" {
+			osType = envelope.VirtualSystem.OperatingSystem.Description
+		}
+		return virtualboxArchitecture(osType), nil
 	}
 
-	f, err := os.Open(ovf)
-	if err != nil {
-		return "", err
+	if vbox != "" {
+		machine, err := readVbox(vbox)
+		if err != nil {
+			return "", err
+		}
+		return virtualboxArchitecture(machine.OSType), nil
 	}
-	defer f.Close()
+	return "", nil
+}
 
-	data, err := ioutil.ReadAll(f)
-	if err != nil {
-		return "", err
+// virtualboxArchitecture returns the architecture of an OS type of
+// VirtualBox, like Ubuntu_64 or Ubuntu_arm64. The ones without suffix are
+// 32-bit.
+func virtualboxArchitecture(osType string) string {
+	osType = strings.ToLower(osType)
+	switch {
+	case osType == "", strings.Contains(osType, " "):
+		// Not an OS type
+		return ""
+	case strings.HasSuffix(osType, "_arm64"):
+		return "arm64"
+	case strings.HasSuffix(osType, "_arm32"):
+		return "arm"
+	case strings.HasSuffix(osType, "_64"):
+		return "amd64"
 	}
+	return "i386"
+}
 
-	re := regexp.MustCompile(`<Adapter slot="0".+?MACAddress="(.+?)"`)
-	matches := re.FindSubmatch(data)
-	if matches == nil {
-		return "", errors.New("can't find base mac address in OVF")
+// readOvaOvf reads the OVF of an OVA.
+func readOvaOvf(src string) ([]byte, error) {
+	f, err := os.Open(src)
+	if err != nil {
+		return nil, err
 	}
+	defer f.Close()
 
-	log.Printf("Base mac address: %s", string(matches[1]))
-	return string(matches[1]), nil
+	var data []byte
+	errFound := errors.New("found")
+	tarReader := tar.NewReader(f)
+	err = walkTar(tarReader, defaultExtractLimits, func(name string, hdr *tar.Header) error {
+		if hdr.Typeflag != tar.TypeReg || filepath.Ext(name) != ".ovf" {
+			return nil
+		}
+		var err error
+		if data, err = io.ReadAll(io.LimitReader(tarReader, 16<<20)); err != nil {
+			return err
+		}
+		return errFound
+	})
+	if err == errFound {
+		return data, nil
+	}
+	if err == nil {
+		err = fmt.Errorf("No OVF in %s", src)
+	}
+	return nil, err
 }
 
-// DecompressOva takes an ova file and decompresses it into the target
-// directory.
-func DecompressOva(dir, src string) error {
-	log.Printf("Turning ova to dir: %s => %s", src, dir)
+// streamOva passes the OVF of an OVA to ovf, with its base name, and returns
+// the other files of the OVA, to be streamed from it into the box. The files
+// are named relative to the directory of the OVF, as its references are, so
+// that they stay valid once the OVF is at the root of the box.
+func streamOva(src string, ovf func(name string, r io.Reader) error) ([]BoxFile, error) {
 	srcF, err := os.Open(src)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer srcF.Close()
 
+	var files []BoxFile
+	ovfDir := "."
+	index := -1
 	tarReader := tar.NewReader(srcF)
-	for {
-		hdr, err := tarReader.Next()
-		if hdr == nil || err == io.EOF {
-			break
+	err = walkTar(tarReader, defaultExtractLimits, func(name string, hdr *tar.Header) error {
+		index++
+		switch {
+		case hdr.Typeflag == tar.TypeDir:
+			return nil
+		case hdr.Typeflag != tar.TypeReg:
+			return fmt.Errorf("Unsupported entry in OVA: %s", hdr.Name)
+		case filepath.Ext(name) == ".ovf":
+			ovfDir = path.Dir(name)
+			return ovf(path.Base(name), tarReader)
 		}
-		if err != nil {
-			return err
-		}
-
-		// We use the fileinfo to get the file name because we are not
-		// expecting path information as from the tar header. It's important
-		// that we not use the path name from the tar header without checking
-		// for the presence of `..`. If we accidentally allow for that, we can
-		// open ourselves up to a path traversal vulnerability.
-		info := hdr.FileInfo()
 
-		// Shouldn't be any directories, skip them
-		if info.IsDir() {
-			continue
-		}
+		entry := index
+		files = append(files, BoxFile{
+			Name: name,
+			Size: hdr.Size,
+			Mode: hdr.FileInfo().Mode().Perm(),
+			Open: func() (io.ReadCloser, error) {
+				return openTarEntry(src, entry, name)
+			},
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
 
-		// We wrap this in an anonymous function so that the defers
-		// inside are handled more quickly so we can give up file handles.
-		err = func() error {
-			path := filepath.Join(dir, info.Name())
-			output, err := os.Create(path)
-			if err != nil {
-				return err
+	if ovfDir != "." {
+		for i, f := range files {
+			if !strings.HasPrefix(f.Name, ovfDir+"/") {
+				return nil, fmt.Errorf("%s of the OVA is not in the directory of its OVF, %s", f.Name, ovfDir)
 			}
-			defer output.Close()
-
-			_ = os.Chmod(path, info.Mode())
-			_ = os.Chtimes(path, hdr.AccessTime, hdr.ModTime)
-			_, err = io.Copy(output, tarReader)
-			return err
-		}()
-		if err != nil {
-			return err
+			files[i].Name = strings.TrimPrefix(f.Name, ovfDir+"/")
 		}
 	}
-
-	return nil
+	return files, nil
 }
 
 var vboxVagrantfile = `
 Vagrant.configure("2") do |config|
   config.vm.base_mac = "%s"
-end
+%send
 `
@@ -0,0 +1,588 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package vagrant
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+)
+
+// ovfEnvelope is the part of an OVF the box is made from.
+type ovfEnvelope struct {
+	ovfReferences
+	Disks []struct {
+		ID      string `xml:"diskId,attr"`
+		FileRef string `xml:"fileRef,attr"`
+	} `xml:"DiskSection>Disk"`
+	VirtualSystem struct {
+		ID              string `xml:"id,attr"`
+		OperatingSystem struct {
+			Description string `xml:"Description"`
+			// OSType is the OS type of VirtualBox
+			OSType string `xml:"OSType"`
+			// VMwareOSType is the guest ID of vSphere
+			VMwareOSType string `xml:"osType,attr"`
+		} `xml:"OperatingSystemSection"`
+		SystemType string `xml:"VirtualHardwareSection>System>VirtualSystemType"`
+		Items      []struct {
+			InstanceID      int    `xml:"InstanceID"`
+			ResourceType    int    `xml:"ResourceType"`
+			ResourceSubType string `xml:"ResourceSubType"`
+			VirtualQuantity int64  `xml:"VirtualQuantity"`
+			AllocationUnits string `xml:"AllocationUnits"`
+			Address         string `xml:"Address"`
+			Parent          int    `xml:"Parent"`
+			AddressOnParent int    `xml:"AddressOnParent"`
+			HostResource    string `xml:"HostResource"`
+		} `xml:"VirtualHardwareSection>Item"`
+		// Configs are the extra settings of VMs exported by VMware
+		Configs []struct {
+			Key   string `xml:"key,attr"`
+			Value string `xml:"value,attr"`
+		} `xml:"VirtualHardwareSection>Config"`
+		// Machine holds the settings of the VM, in OVFs exported by
+		// VirtualBox
+		Machine *vboxMachine `xml:"Machine"`
+	} `xml:"VirtualSystem"`
+}
+
+// vboxMachine is the part of the settings of a VirtualBox VM, as found in a
+// .vbox file or in an OVF exported by VirtualBox, the box is made from.
+type vboxMachine struct {
+	Name     string `xml:"name,attr"`
+	OSType   string `xml:"OSType,attr"`
+	Hardware struct {
+		CPU struct {
+			Count int `xml:"count,attr"`
+		} `xml:"CPU"`
+		Memory struct {
+			RAMSize int64 `xml:"RAMSize,attr"`
+		} `xml:"Memory"`
+		Firmware         vboxFirmware            `xml:"Firmware"`
+		PlatformFirmware vboxFirmware            `xml:"Platform>Firmware"`
+		Adapters         []vboxAdapter           `xml:"Network>Adapter"`
+		Controllers      []vboxStorageController `xml:"StorageControllers>StorageController"`
+	} `xml:"Hardware"`
+	Controllers []vboxStorageController `xml:"StorageControllers>StorageController"`
+	HardDisks   []struct {
+		UUID     string `xml:"uuid,attr"`
+		Location string `xml:"location,attr"`
+	} `xml:"MediaRegistry>HardDisks>HardDisk"`
+}
+
+type vboxFirmware struct {
+	Type string `xml:"type,attr"`
+}
+
+type vboxAdapter struct {
+	Slot       int    `xml:"slot,attr"`
+	Enabled    bool   `xml:"enabled,attr"`
+	MACAddress string `xml:"MACAddress,attr"`
+	Type       string `xml:"type,attr"`
+}
+
+type vboxStorageController struct {
+	Name     string `xml:"name,attr"`
+	Type     string `xml:"type,attr"`
+	Attached []struct {
+		Type  string `xml:"type,attr"`
+		Port  int    `xml:"port,attr"`
+		Image struct {
+			UUID string `xml:"uuid,attr"`
+		} `xml:"Image"`
+	} `xml:"AttachedDevice"`
+}
+
+// vboxHardware is what the box needs to know about the hardware of the VM.
+type vboxHardware struct {
+	// Firmware is either bios or efi.
+	Firmware string
+	// Memory in MiB.
+	Memory int64
+	CPUs   int
+	// Adapters are the enabled network adapters.
+	Adapters []vboxAdapter
+	// DiskController is the type of the controller of the first disk, as
+	// VirtualBox names it in its settings.
+	DiskController string
+	// DiskControllerName is the name of that controller once the VM is
+	// imported.
+	DiskControllerName string
+}
+
+// readOvf parses an OVF.
+func readOvf(ovf string) (*ovfEnvelope, error) {
+	data, err := os.ReadFile(ovf)
+	if err != nil {
+		return nil, err
+	}
+	return parseOvf(data)
+}
+
+// parseOvf parses the contents of an OVF.
+func parseOvf(data []byte) (*ovfEnvelope, error) {
+	var envelope ovfEnvelope
+	if err := xml.Unmarshal(data, &envelope); err != nil {
+		return nil, fmt.Errorf("Error reading OVF: %s", err)
+	}
+	return &envelope, nil
+}
+
+// readVbox parses the settings of a VM in a .vbox file.
+func readVbox(vbox string) (*vboxMachine, error) {
+	data, err := os.ReadFile(vbox)
+	if err != nil {
+		return nil, err
+	}
+	var settings struct {
+		Machine vboxMachine `xml:"Machine"`
+	}
+	if err := xml.Unmarshal(data, &settings); err != nil {
+		return nil, fmt.Errorf("Error reading %s: %s", vbox, err)
+	}
+	return &settings.Machine, nil
+}
+
+// hardware returns the hardware of the VM, from the settings VirtualBox
+// exports when there are some, or from the virtual hardware items.
+func (e *ovfEnvelope) hardware() vboxHardware {
+	if e.VirtualSystem.Machine != nil {
+		return e.VirtualSystem.Machine.hardware()
+	}
+
+	hw := vboxHardware{Firmware: "bios", CPUs: 1}
+	for _, config := range e.VirtualSystem.Configs {
+		if config.Key == "firmware" && config.Value == "efi" {
+			hw.Firmware = "efi"
+		}
+	}
+	slot := 0
+	for _, item := range e.VirtualSystem.Items {
+		switch item.ResourceType {
+		case ovfResourceCPU:
+			hw.CPUs = int(item.VirtualQuantity)
+		case ovfResourceMemory:
+			hw.Memory = item.VirtualQuantity
+			if strings.EqualFold(item.AllocationUnits, "GigaBytes") || item.AllocationUnits == "byte * 2^30" {
+				hw.Memory *= 1024
+			}
+		case ovfResourceEthernet:
+			hw.Adapters = append(hw.Adapters, vboxAdapter{
+				Slot:       slot,
+				Enabled:    true,
+				MACAddress: strings.ToUpper(strings.ReplaceAll(item.Address, ":", "")),
+				Type:       vboxAdapterType(item.ResourceSubType),
+			})
+			slot++
+		case ovfResourceIDE, ovfResourceSCSI, ovfResourceOtherStorage:
+			if hw.DiskController == "" {
+				hw.DiskController = vboxControllerType(item.ResourceSubType)
+				hw.DiskControllerName = ovfControllers[hw.DiskController].Name
+			}
+		}
+	}
+	return hw
+}
+
+func (m *vboxMachine) hardware() vboxHardware {
+	hw := vboxHardware{
+		Firmware: "bios",
+		Memory:   m.Hardware.Memory.RAMSize,
+		CPUs:     max(m.Hardware.CPU.Count, 1),
+	}
+	for _, firmware := range []vboxFirmware{m.Hardware.Firmware, m.Hardware.PlatformFirmware} {
+		if strings.HasPrefix(strings.ToUpper(firmware.Type), "EFI") {
+			hw.Firmware = "efi"
+		}
+	}
+	for _, adapter := range m.Hardware.Adapters {
+		if adapter.Enabled {
+			hw.Adapters = append(hw.Adapters, adapter)
+		}
+	}
+	for _, controller := range m.controllers() {
+		for _, device := range controller.Attached {
+			if device.Type == "HardDisk" && hw.DiskController == "" {
+				hw.DiskController = controller.Type
+				hw.DiskControllerName = controller.Name
+			}
+		}
+	}
+	return hw
+}
+
+// controllers returns the storage controllers of the VM, which are part of
+// the hardware in the latest versions of the settings.
+func (m *vboxMachine) controllers() []vboxStorageController {
+	return slices.Concat(m.Controllers, m.Hardware.Controllers)
+}
+
+// baseMacAddress returns the MAC address of the adapter in slot 0.
+func (hw vboxHardware) baseMacAddress() string {
+	for _, adapter := range hw.Adapters {
+		if adapter.Slot == 0 {
+			return adapter.MACAddress
+		}
+	}
+	return ""
+}
+
+// vagrantfile returns the settings of the box Vagrantfile for the hardware,
+// so that they are kept when Vagrant imports it. Vagrant clears the network
+// adapters other than the first one, which are set up again with NAT.
+func (hw vboxHardware) vagrantfile() string {
+	var settings strings.Builder
+	if hw.Memory > 0 {
+		fmt.Fprintf(&settings, "    vb.memory = %d\n", hw.Memory)
+	}
+	fmt.Fprintf(&settings, "    vb.cpus = %d\n", hw.CPUs)
+	if hw.Firmware == "efi" {
+		settings.WriteString("    vb.customize [\"modifyvm\", :id, \"--firmware\", \"efi\"]\n")
+	}
+	for _, adapter := range hw.Adapters {
+		if adapter.Slot == 0 {
+			continue
+		}
+		nic := adapter.Slot + 1
+		fmt.Fprintf(&settings, "    vb.customize [\"modifyvm\", :id, \"--nic%d\", \"nat\"", nic)
+		if _, ok := ovfAdapters[adapter.Type]; ok {
+			fmt.Fprintf(&settings, ", \"--nictype%d\", %q", nic, adapter.Type)
+		}
+		settings.WriteString("]\n")
+	}
+	if controller, ok := ovfControllers[hw.DiskController]; ok && hw.DiskControllerName != "" {
+		fmt.Fprintf(&settings, "    vb.customize [\"storagectl\", :id, \"--name\", %q, \"--controller\", %q]\n",
+			hw.DiskControllerName, controller.Controller)
+	}
+	return "  config.vm.provider \"virtualbox\" do |vb|\n" + settings.String() + "  end\n"
+}
+
+// metadata returns the hardware as it is written to metadata.json.
+func (hw vboxHardware) metadata() map[string]interface{} {
+	adapters := make([]map[string]interface{}, 0, len(hw.Adapters))
+	for _, adapter := range hw.Adapters {
+		adapters = append(adapters, map[string]interface{}{
+			"slot": adapter.Slot,
+			"type": adapter.Type,
+			"mac":  adapter.MACAddress,
+		})
+	}
+	metadata := map[string]interface{}{
+		"firmware":         hw.Firmware,
+		"cpus":             hw.CPUs,
+		"network_adapters": adapters,
+	}
+	if hw.Memory > 0 {
+		metadata["memory"] = hw.Memory
+	}
+	if hw.DiskController != "" {
+		metadata["disk_controller"] = hw.DiskController
+	}
+	return metadata
+}
+
+// Resource types of the virtual hardware items of an OVF.
+const (
+	ovfResourceCPU          = 3
+	ovfResourceMemory       = 4
+	ovfResourceIDE          = 5
+	ovfResourceSCSI         = 6
+	ovfResourceEthernet     = 10
+	ovfResourceDisk         = 17
+	ovfResourceOtherStorage = 20
+)
+
+// ovfControllers maps the storage controllers of VirtualBox to the resource
+// type and subtype VirtualBox exports them as, the name VirtualBox gives them
+// when importing an OVF without its settings, and their type for
+// VBoxManage storagectl.
+var ovfControllers = map[string]struct {
+	ResourceType int
+	SubType      string
+	Name         string
+	Controller   string
+}{
+	"PIIX3":       {ovfResourceIDE, "PIIX3", "IDE", "PIIX3"},
+	"PIIX4":       {ovfResourceIDE, "PIIX4", "IDE", "PIIX4"},
+	"ICH6":        {ovfResourceIDE, "ICH6", "IDE", "ICH6"},
+	"AHCI":        {ovfResourceOtherStorage, "AHCI", "SATA", "IntelAHCI"},
+	"LsiLogic":    {ovfResourceSCSI, "lsilogic", "SCSI", "LSILogic"},
+	"BusLogic":    {ovfResourceSCSI, "buslogic", "SCSI", "BusLogic"},
+	"LsiLogicSas": {ovfResourceOtherStorage, "LsiLogicSas", "SAS", "LSILogicSAS"},
+	"NVMe":        {ovfResourceOtherStorage, "NVMe", "NVMe", "NVMe"},
+	"VirtioSCSI":  {ovfResourceOtherStorage, "VirtioSCSI", "VirtioSCSI", "VirtIO"},
+}
+
+// vboxControllerType returns the type of the storage controller of
+// VirtualBox for a subtype of an OVF, or the subtype when it has none.
+func vboxControllerType(subType string) string {
+	for name, controller := range ovfControllers {
+		if strings.EqualFold(controller.SubType, subType) {
+			return name
+		}
+	}
+	return subType
+}
+
+// ovfAdapters maps the network adapters of VirtualBox to the subtype
+// VirtualBox exports them as.
+var ovfAdapters = map[string]string{
+	"Am79C970A": "PCNet32",
+	"Am79C973":  "PCNet32",
+	"82540EM":   "E1000",
+	"82543GC":   "E1000",
+	"82545EM":   "E1000",
+	"virtio":    "virtio-net",
+}
+
+// vboxAdapterTypes maps the subtypes of network adapters of an OVF to the
+// network adapters of VirtualBox, which VirtualBox imports them as.
+var vboxAdapterTypes = map[string]string{
+	"pcnet32":    "Am79C973",
+	"e1000":      "82540EM",
+	"virtio-net": "virtio",
+}
+
+// vboxAdapterType returns the network adapter of VirtualBox for a subtype of
+// an OVF, or the subtype when it has none.
+func vboxAdapterType(subType string) string {
+	if adapter, ok := vboxAdapterTypes[strings.ToLower(subType)]; ok {
+		return adapter
+	}
+	return subType
+}
+
+type ovfDisk struct {
+	ID       string
+	FileID   string
+	Href     string
+	Capacity int64
+	// Parent is the instance ID of the controller item the disk is attached
+	// to, at Port
+	Parent int
+	Port   int
+}
+
+type ovfItem struct {
+	InstanceID      int
+	ResourceType    int
+	ResourceSubType string
+	Name            string
+	Quantity        int64
+	Units           string
+	HostResource    string
+	Parent          int
+	Address         int
+	Connection      string
+}
+
+// ovfFromVbox writes an OVF describing the VM of a .vbox file, for VirtualBox
+// to import it along with its disks, which are found among the files of the
+// box by name.
+func ovfFromVbox(w io.Writer, machine *vboxMachine, files []BoxFile) error {
+	hw := machine.hardware()
+	var items []ovfItem
+	var disks []ovfDisk
+	addItem := func(item ovfItem) int {
+		item.InstanceID = len(items) + 1
+		items = append(items, item)
+		return item.InstanceID
+	}
+
+	addItem(ovfItem{ResourceType: ovfResourceCPU, Name: fmt.Sprintf("%d virtual CPU", hw.CPUs), Quantity: int64(hw.CPUs), Units: "hertz * 10^6"})
+	addItem(ovfItem{ResourceType: ovfResourceMemory, Name: fmt.Sprintf("%d MB of memory", hw.Memory), Quantity: hw.Memory, Units: "byte * 2^20"})
+
+	locations := make(map[string]string)
+	for _, disk := range machine.HardDisks {
+		locations[disk.UUID] = path.Base(filepath.ToSlash(disk.Location))
+	}
+	for i, controller := range machine.controllers() {
+		resource, ok := ovfControllers[controller.Type]
+		if !ok {
+			return fmt.Errorf("Unsupported storage controller %s in .vbox file", controller.Type)
+		}
+		parent := addItem(ovfItem{
+			ResourceType:    resource.ResourceType,
+			ResourceSubType: resource.SubType,
+			Name:            fmt.Sprintf("%s Controller", controller.Type),
+			Address:         i,
+		})
+		for _, device := range controller.Attached {
+			if device.Type != "HardDisk" {
+				continue
+			}
+			location, ok := locations[device.Image.UUID]
+			if !ok {
+				return fmt.Errorf("Disk %s of the .vbox file isn't in its media registry", device.Image.UUID)
+			}
+			f, ok := findBoxFile(files, location)
+			if !ok {
+				return fmt.Errorf("Disk %s of the .vbox file is missing from the artifact", location)
+			}
+			if !strings.EqualFold(path.Ext(location), ".vmdk") {
+				return fmt.Errorf("Disk %s of the .vbox file is not a VMDK, the only format of the disks "+
+					"of VirtualBox boxes: convert it with VBoxManage clonemedium --format VMDK", location)
+			}
+			capacity, err := vmdkCapacity(f.Path)
+			if err != nil {
+				return fmt.Errorf("Error reading disk %s: %s", location, err)
+			}
+			disk := ovfDisk{
+				ID:       fmt.Sprintf("vmdisk%d", len(disks)+1),
+				FileID:   fmt.Sprintf("file%d", len(disks)+1),
+				Href:     f.Name,
+				Capacity: capacity,
+				Parent:   parent,
+				Port:     device.Port,
+			}
+			disks = append(disks, disk)
+			addItem(ovfItem{
+				ResourceType: ovfResourceDisk,
+				Name:         fmt.Sprintf("Disk Image %d", len(disks)),
+				HostResource: "/disk/" + disk.ID,
+				Parent:       parent,
+				Address:      device.Port,
+			})
+		}
+	}
+	if len(disks) == 0 {
+		return fmt.Errorf("No disk attached in .vbox file")
+	}
+
+	for _, adapter := range hw.Adapters {
+		subType, ok := ovfAdapters[adapter.Type]
+		if !ok {
+			subType = "E1000"
+		}
+		addItem(ovfItem{
+			ResourceType:    ovfResourceEthernet,
+			ResourceSubType: subType,
+			Name:            "Ethernet adapter on 'NAT'",
+			Connection:      "NAT",
+		})
+	}
+
+	return ovfTemplate.Execute(w, map[string]interface{}{
+		"Name":   machine.Name,
+		"OSType": machine.OSType,
+		"Disks":  disks,
+		"Items":  items,
+	})
+}
+
+// findBoxFile returns the file of the box with the given base name.
+func findBoxFile(files []BoxFile, name string) (BoxFile, bool) {
+	for _, f := range files {
+		if path.Base(filepath.ToSlash(f.Name)) == name {
+			return f, true
+		}
+	}
+	return BoxFile{}, false
+}
+
+// vmdkCapacity returns the size of the disk a VMDK holds, from the header of
+// sparse extents. Other VMDKs are assumed to be as big as their file.
+func vmdkCapacity(vmdk string) (int64, error) {
+	f, err := os.Open(vmdk)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	header := make([]byte, 20)
+	if _, err := io.ReadFull(f, header); err == nil && bytes.Equal(header[:4], []byte("KDMV")) {
+		// The capacity is counted in sectors
+		return int64(binary.LittleEndian.Uint64(header[12:20])) * 512, nil
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+var ovfTemplate = template.Must(template.New("ovf").Funcs(template.FuncMap{"xml": xmlEscape}).Parse(`<?xml version="1.0"?>
+<Envelope ovf:version="1.0" xml:lang="en-US" xmlns="http://schemas.dmtf.org/ovf/envelope/1" xmlns:ovf="http://schemas.dmtf.org/ovf/envelope/1" xmlns:rasd="http://schemas.dmtf.org/wbem/wscim/1/cim-schema/2/CIM_ResourceAllocationSettingData" xmlns:vssd="http://schemas.dmtf.org/wbem/wscim/1/cim-schema/2/CIM_VirtualSystemSettingData" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xmlns:vbox="http://www.virtualbox.org/ovf/machine">
+  <References>
+{{- range .Disks}}
+    <File ovf:id="{{.FileID}}" ovf:href="{{xml .Href}}"/>
+{{- end}}
+  </References>
+  <DiskSection>
+    <Info>List of the virtual disks used in the package</Info>
+{{- range .Disks}}
+    <Disk ovf:capacity="{{.Capacity}}" ovf:diskId="{{.ID}}" ovf:fileRef="{{.FileID}}" ovf:format="http://www.vmware.com/interfaces/specifications/vmdk.html#sparse"/>
+{{- end}}
+  </DiskSection>
+  <NetworkSection>
+    <Info>Logical networks used in the package</Info>
+    <Network ovf:name="NAT">
+      <Description>Logical network used by this appliance.</Description>
+    </Network>
+  </NetworkSection>
+  <VirtualSystem ovf:id="{{xml .Name}}">
+    <Info>A virtual machine</Info>
+    <OperatingSystemSection ovf:id="1">
+      <Info>The kind of installed guest operating system</Info>
+      <Description>{{xml .OSType}}</Description>
+      <vbox:OSType ovf:required="false">{{xml .OSType}}</vbox:OSType>
+    </OperatingSystemSection>
+    <VirtualHardwareSection>
+      <Info>Virtual hardware requirements for a virtual machine</Info>
+      <System>
+        <vssd:ElementName>Virtual Hardware Family</vssd:ElementName>
+        <vssd:InstanceID>0</vssd:InstanceID>
+        <vssd:VirtualSystemIdentifier>{{xml .Name}}</vssd:VirtualSystemIdentifier>
+        <vssd:VirtualSystemType>virtualbox-2.2</vssd:VirtualSystemType>
+      </System>
+{{- range .Items}}
+      <Item>
+{{- if eq .ResourceType 17}}
+        <rasd:AddressOnParent>{{.Address}}</rasd:AddressOnParent>
+{{- else if or (eq .ResourceType 5) (eq .ResourceType 6) (eq .ResourceType 20)}}
+        <rasd:Address>{{.Address}}</rasd:Address>
+{{- end}}
+{{- if .Units}}
+        <rasd:AllocationUnits>{{.Units}}</rasd:AllocationUnits>
+{{- end}}
+{{- if .Connection}}
+        <rasd:AutomaticAllocation>true</rasd:AutomaticAllocation>
+        <rasd:Connection>{{.Connection}}</rasd:Connection>
+{{- end}}
+        <rasd:ElementName>{{xml .Name}}</rasd:ElementName>
+{{- if .HostResource}}
+        <rasd:HostResource>{{.HostResource}}</rasd:HostResource>
+{{- end}}
+        <rasd:InstanceID>{{.InstanceID}}</rasd:InstanceID>
+{{- if .Parent}}
+        <rasd:Parent>{{.Parent}}</rasd:Parent>
+{{- end}}
+{{- if .ResourceSubType}}
+        <rasd:ResourceSubType>{{.ResourceSubType}}</rasd:ResourceSubType>
+{{- end}}
+        <rasd:ResourceType>{{.ResourceType}}</rasd:ResourceType>
+{{- if .Quantity}}
+        <rasd:VirtualQuantity>{{.Quantity}}</rasd:VirtualQuantity>
+{{- end}}
+      </Item>
+{{- end}}
+    </VirtualHardwareSection>
+  </VirtualSystem>
+</Envelope>
+`))
@@ -4,28 +4,299 @@
 package vagrant
 
 import (
+	"archive/tar"
+	"encoding/binary"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
-	"github.com/hashicorp/packer-plugin-sdk/tmp"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 	"github.com/stretchr/testify/assert"
 )
 
+// testVBoxOvf is an OVF as exported by VirtualBox.
+const testVBoxOvf = `<?xml version="1.0"?>
+<Envelope ovf:version="1.0" xmlns="http://schemas.dmtf.org/ovf/envelope/1" xmlns:ovf="http://schemas.dmtf.org/ovf/envelope/1" xmlns:rasd="http://schemas.dmtf.org/wbem/wscim/1/cim-schema/2/CIM_ResourceAllocationSettingData" xmlns:vbox="http://www.virtualbox.org/ovf/machine">
+  <References>
+    <File ovf:id="file1" ovf:href="packer-vm-disk001.vmdk"/>
+  </References>
+  <VirtualSystem ovf:id="packer-vm">
+    <OperatingSystemSection ovf:id="94">
+      <Description>Ubuntu_64</Description>
+      <vbox:OSType ovf:required="false">Ubuntu_64</vbox:OSType>
+    </OperatingSystemSection>
+    <VirtualHardwareSection>
+      <Item>
+        <rasd:ResourceType>3</rasd:ResourceType>
+        <rasd:VirtualQuantity>2</rasd:VirtualQuantity>
+      </Item>
+    </VirtualHardwareSection>
+    <vbox:Machine ovf:required="false" version="1.19-linux" name="packer-vm" OSType="Ubuntu_64">
+      <Hardware>
+        <CPU count="2"/>
+        <Memory RAMSize="2048"/>
+        <Firmware type="EFI"/>
+        <Network>
+          <Adapter slot="0" enabled="true" MACAddress="080027A5B1C2" type="82540EM">
+            <NAT/>
+          </Adapter>
+          <Adapter slot="1" enabled="true" MACAddress="080027D3E4F5" type="virtio">
+            <InternalNetwork name="intnet"/>
+          </Adapter>
+          <Adapter slot="2" MACAddress="080027000000" type="82540EM"/>
+        </Network>
+      </Hardware>
+      <StorageControllers>
+        <StorageController name="SATA Controller" type="AHCI" PortCount="1">
+          <AttachedDevice type="HardDisk" hotpluggable="false" port="0" device="0">
+            <Image uuid="{6d3c8b4e-1e4f-4a51-9d5e-2f1c9b0a7d11}"/>
+          </AttachedDevice>
+        </StorageController>
+      </StorageControllers>
+    </vbox:Machine>
+  </VirtualSystem>
+</Envelope>
+`
+
 func TestVBoxProvider_impl(t *testing.T) {
 	var _ Provider = new(VBoxProvider)
 }
 
-func TestDecomressOVA(t *testing.T) {
-	td, err := tmp.Dir("pp-vagrant-virtualbox")
+func TestVBoxProvider_Process(t *testing.T) {
+	artifactDir := t.TempDir()
+	ovf := filepath.Join(artifactDir, "packer-vm.ovf")
+	disk := filepath.Join(artifactDir, "packer-vm-disk001.vmdk")
+	err := os.WriteFile(ovf, []byte(testVBoxOvf), 0644)
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(disk, []byte("disk"), 0644))
+
+	artifact := &packersdk.MockArtifact{
+		FilesValue: []string{ovf, disk},
+	}
+	p := new(VBoxProvider)
+	vagrantfile, metadata, files, err := p.Process(testUi(), artifact, t.TempDir())
+	assert.NoError(t, err)
+	assert.Contains(t, vagrantfile, `config.vm.base_mac = "080027A5B1C2"`)
+	assert.Contains(t, vagrantfile, "vb.memory = 2048")
+	assert.Contains(t, vagrantfile, "vb.cpus = 2")
+	assert.Contains(t, vagrantfile, `vb.customize ["modifyvm", :id, "--firmware", "efi"]`)
+	assert.Equal(t, "efi", metadata["firmware"])
+	assert.Equal(t, int64(2048), metadata["memory"])
+	assert.Equal(t, 2, metadata["cpus"])
+	assert.Equal(t, "AHCI", metadata["disk_controller"])
+	assert.Equal(t, []map[string]interface{}{
+		{"slot": 0, "type": "82540EM", "mac": "080027A5B1C2"},
+		{"slot": 1, "type": "virtio", "mac": "080027D3E4F5"},
+	}, metadata["network_adapters"])
+	assert.Equal(t, []BoxFile{
+		{Name: "box.ovf", Path: ovf},
+		{Name: "packer-vm-disk001.vmdk", Path: disk},
+	}, files)
+}
+
+func TestVBoxProvider_Process_ova(t *testing.T) {
+	ova := filepath.Join(t.TempDir(), "packer-vm.ova")
+	archive := testTar(t, []tarEntry{
+		{Name: "export/", Type: tar.TypeDir},
+		{Name: "export/packer-vm.ovf", Type: tar.TypeReg, Body: testVBoxOvf},
+		{Name: "export/packer-vm-disk001.vmdk", Type: tar.TypeReg, Body: "disk"},
+	})
+	assert.NoError(t, os.WriteFile(ova, archive.Bytes(), 0644))
+
+	artifact := &packersdk.MockArtifact{
+		FilesValue: []string{ova},
+	}
+	dir := t.TempDir()
+	p := new(VBoxProvider)
+	vagrantfile, _, files, err := p.Process(testUi(), artifact, dir)
+	assert.NoError(t, err)
+	assert.Contains(t, vagrantfile, `config.vm.base_mac = "080027A5B1C2"`)
+
+	// Only the OVF is unpacked, the disk is streamed from the OVA, both named
+	// relative to the directory of the OVF as its references are
+	_, err = os.Stat(filepath.Join(dir, "box.ovf"))
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+	assert.Equal(t, "packer-vm-disk001.vmdk", files[0].Name)
+	assert.Equal(t, int64(4), files[0].Size)
+	r, err := files[0].Open()
+	assert.NoError(t, err)
+	defer r.Close()
+	contents, err := io.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Equal(t, "disk", string(contents))
+}
+
+func TestVBoxProvider_Process_ovaOutsideOvfDir(t *testing.T) {
+	ova := filepath.Join(t.TempDir(), "packer-vm.ova")
+	archive := testTar(t, []tarEntry{
+		{Name: "export/packer-vm.ovf", Type: tar.TypeReg, Body: testVBoxOvf},
+		{Name: "packer-vm-disk001.vmdk", Type: tar.TypeReg, Body: "disk"},
+	})
+	assert.NoError(t, os.WriteFile(ova, archive.Bytes(), 0644))
+
+	artifact := &packersdk.MockArtifact{
+		FilesValue: []string{ova},
+	}
+	_, _, _, err := new(VBoxProvider).Process(testUi(), artifact, t.TempDir())
+	assert.ErrorContains(t, err, "not in the directory of its OVF")
+}
+
+func TestVBoxProvider_DetectArchitecture(t *testing.T) {
+	ovf := `<Envelope><VirtualSystem ovf:id="packer-vm">
+  <OperatingSystemSection ovf:id="94">
+    <Info>The kind of installed guest operating system</Info>
+    <Description>Ubuntu_arm64</Description>
+    <vbox:OSType ovf:required="false">Ubuntu_arm64</vbox:OSType>
+  </OperatingSystemSection>
+</VirtualSystem></Envelope>`
+	ova := filepath.Join(t.TempDir(), "packer-vm.ova")
+	archive := testTar(t, []tarEntry{
+		{Name: "packer-vm.ovf", Type: tar.TypeReg, Body: ovf},
+		{Name: "packer-vm-disk001.vmdk", Type: tar.TypeReg, Body: "disk"},
+	})
+	assert.NoError(t, os.WriteFile(ova, archive.Bytes(), 0644))
+
+	arch, err := new(VBoxProvider).DetectArchitecture(&packersdk.MockArtifact{FilesValue: []string{ova}})
+	assert.NoError(t, err)
+	assert.Equal(t, "arm64", arch)
+
+	for osType, expected := range map[string]string{
+		"Ubuntu_64":     "amd64",
+		"Windows11_64":  "amd64",
+		"WindowsXP":     "i386",
+		"Debian_arm32":  "arm",
+		"Some other OS": "",
+	} {
+		assert.Equal(t, expected, virtualboxArchitecture(osType), osType)
+	}
+}
+
+// testVbox is the .vbox file of a VM with a VMDK disk.
+const testVbox = `<?xml version="1.0"?>
+<VirtualBox xmlns="http://www.virtualbox.org/" version="1.19-linux">
+  <Machine uuid="{0b7c6f4e-5a1d-4c8e-9f2b-3e6d1a0c9b88}" name="packer-vm" OSType="Ubuntu_arm64">
+    <MediaRegistry>
+      <HardDisks>
+        <HardDisk uuid="{6d3c8b4e-1e4f-4a51-9d5e-2f1c9b0a7d11}" location="packer-vm.vmdk" format="VMDK" type="Normal"/>
+      </HardDisks>
+    </MediaRegistry>
+    <Hardware>
+      <CPU count="4"/>
+      <Memory RAMSize="4096"/>
+      <Network>
+        <Adapter slot="0" enabled="true" MACAddress="080027A5B1C2" type="virtio">
+          <NAT/>
+        </Adapter>
+        <Adapter slot="1" enabled="true" MACAddress="080027A5B1C3" type="82540EM">
+          <InternalNetwork name="intnet"/>
+        </Adapter>
+      </Network>
+      <StorageControllers>
+        <StorageController name="NVMe" type="NVMe" PortCount="1">
+          <AttachedDevice type="HardDisk" hotpluggable="false" port="0" device="0">
+            <Image uuid="{6d3c8b4e-1e4f-4a51-9d5e-2f1c9b0a7d11}"/>
+          </AttachedDevice>
+        </StorageController>
+      </StorageControllers>
+    </Hardware>
+  </Machine>
+</VirtualBox>
+`
+
+func TestVBoxProvider_Process_vbox(t *testing.T) {
+	artifactDir := t.TempDir()
+	vbox := filepath.Join(artifactDir, "packer-vm.vbox")
+	disk := filepath.Join(artifactDir, "packer-vm.vmdk")
+	err := os.WriteFile(vbox, []byte(testVbox), 0644)
+	assert.NoError(t, err)
+
+	// A sparse extent header for a 16GiB disk
+	header := make([]byte, 512)
+	copy(header, "KDMV")
+	binary.LittleEndian.PutUint64(header[12:], 16<<30/512)
+	assert.NoError(t, os.WriteFile(disk, header, 0644))
+
+	artifact := &packersdk.MockArtifact{
+		FilesValue: []string{vbox, vbox + "-prev", disk},
+	}
+	dir := t.TempDir()
+	p := new(VBoxProvider)
+	vagrantfile, metadata, files, err := p.Process(testUi(), artifact, dir)
 	assert.NoError(t, err)
-	defer os.RemoveAll(td)
+	assert.Contains(t, vagrantfile, `config.vm.base_mac = "080027A5B1C2"`)
+	assert.Contains(t, vagrantfile, "vb.cpus = 4")
+	assert.Contains(t, vagrantfile, `vb.customize ["modifyvm", :id, "--nic2", "nat", "--nictype2", "82540EM"]`)
+	assert.Contains(t, vagrantfile, `vb.customize ["storagectl", :id, "--name", "NVMe", "--controller", "NVMe"]`)
+	assert.Equal(t, "NVMe", metadata["disk_controller"])
 
-	fixture := "./test-fixtures/decompress-tar/outside_parent.tar"
-	err = DecompressOva(td, fixture)
+	// The .vbox file is replaced by an OVF
+	assert.Equal(t, []BoxFile{{Name: "packer-vm.vmdk", Path: disk}}, files)
+	envelope, err := readOvf(filepath.Join(dir, "box.ovf"))
 	assert.NoError(t, err)
-	_, err = os.Stat(filepath.Join(filepath.Base(td), "demo.poc"))
-	assert.Error(t, err)
-	_, err = os.Stat(filepath.Join(td, "demo.poc"))
+	assert.Len(t, envelope.Files, 1)
+	assert.Equal(t, "packer-vm.vmdk", envelope.Files[0].Href)
+	assert.Equal(t, "Ubuntu_arm64", envelope.VirtualSystem.OperatingSystem.OSType)
+	hw := envelope.hardware()
+	assert.Equal(t, 4, hw.CPUs)
+	assert.Equal(t, int64(4096), hw.Memory)
+	assert.Equal(t, "NVMe", hw.DiskController)
+	assert.Len(t, hw.Adapters, 2)
+	assert.Contains(t, string(mustReadFile(t, filepath.Join(dir, "box.ovf"))), `ovf:capacity="17179869184"`)
+
+	arch, err := p.DetectArchitecture(artifact)
 	assert.NoError(t, err)
+	assert.Equal(t, "arm64", arch)
+}
+
+func TestVBoxProvider_Process_vboxVdi(t *testing.T) {
+	artifactDir := t.TempDir()
+	vbox := filepath.Join(artifactDir, "packer-vm.vbox")
+	disk := filepath.Join(artifactDir, "packer-vm.vdi")
+	assert.NoError(t, os.WriteFile(vbox, []byte(strings.ReplaceAll(testVbox, "packer-vm.vmdk", "packer-vm.vdi")), 0644))
+	assert.NoError(t, os.WriteFile(disk, []byte("disk"), 0644))
+
+	artifact := &packersdk.MockArtifact{FilesValue: []string{vbox, disk}}
+	_, _, _, err := new(VBoxProvider).Process(testUi(), artifact, t.TempDir())
+	if err == nil || !strings.Contains(err.Error(), "not a VMDK") {
+		t.Fatalf("a VDI disk should be rejected: %v", err)
+	}
+}
+
+func TestOvfEnvelope_hardware(t *testing.T) {
+	ovf := filepath.Join(t.TempDir(), "vm.ovf")
+	err := os.WriteFile(ovf, []byte(`<Envelope xmlns:rasd="rasd"><VirtualSystem><VirtualHardwareSection>
+  <Item><rasd:ResourceType>3</rasd:ResourceType><rasd:VirtualQuantity>2</rasd:VirtualQuantity></Item>
+  <Item><rasd:AllocationUnits>GigaBytes</rasd:AllocationUnits><rasd:ResourceType>4</rasd:ResourceType><rasd:VirtualQuantity>2</rasd:VirtualQuantity></Item>
+  <Item><rasd:ResourceSubType>lsilogic</rasd:ResourceSubType><rasd:ResourceType>6</rasd:ResourceType></Item>
+  <Item><rasd:Address>08:00:27:a5:b1:c2</rasd:Address><rasd:ResourceSubType>E1000</rasd:ResourceSubType><rasd:ResourceType>10</rasd:ResourceType></Item>
+  <Item><rasd:ResourceSubType>PCNet32</rasd:ResourceSubType><rasd:ResourceType>10</rasd:ResourceType></Item>
+</VirtualHardwareSection></VirtualSystem></Envelope>`), 0644)
+	assert.NoError(t, err)
+
+	envelope, err := readOvf(ovf)
+	assert.NoError(t, err)
+	hw := envelope.hardware()
+	assert.Equal(t, "bios", hw.Firmware)
+	assert.Equal(t, 2, hw.CPUs)
+	assert.Equal(t, int64(2048), hw.Memory)
+	// The controllers and adapters are named as in the settings of
+	// VirtualBox, whatever the OVF
+	assert.Equal(t, "LsiLogic", hw.DiskController)
+	assert.Equal(t, "82540EM", hw.Adapters[0].Type)
+	assert.Equal(t, "080027A5B1C2", hw.baseMacAddress())
+
+	vagrantfile := hw.vagrantfile()
+	assert.Contains(t, vagrantfile, `vb.customize ["modifyvm", :id, "--nic2", "nat", "--nictype2", "Am79C973"]`)
+	assert.Contains(t, vagrantfile, `vb.customize ["storagectl", :id, "--name", "SCSI", "--controller", "LSILogic"]`)
+}
+
+func mustReadFile(t *testing.T, path string) []byte {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
 }
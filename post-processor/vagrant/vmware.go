@@ -4,10 +4,21 @@
 package vagrant
 
 import (
+	"bufio"
+	"errors"
 	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/tmp"
 )
 
 type VMwareProvider struct{}
@@ -16,19 +27,555 @@ func (p *VMwareProvider) KeepInputArtifact() bool {
 	return false
 }
 
-func (p *VMwareProvider) Process(ui packersdk.Ui, artifact packersdk.Artifact, dir string) (vagrantfile string, metadata map[string]interface{}, err error) {
+func (p *VMwareProvider) Process(ui packersdk.Ui, artifact packersdk.Artifact, dir string) (vagrantfile string, metadata map[string]interface{}, files []BoxFile, err error) {
 	// Create the metadata
 	metadata = map[string]interface{}{"provider": "vmware_desktop"}
 
-	// Copy all of the original contents into the temporary directory
+	hasVMX := false
 	for _, path := range artifact.Files() {
-		ui.Message(fmt.Sprintf("Copying: %s", path))
+		if filepath.Ext(path) == ".vmx" {
+			hasVMX = true
+		}
+	}
 
-		dstPath := filepath.Join(dir, filepath.Base(path))
-		if err = CopyContents(dstPath, path); err != nil {
+	// Add all of the original contents to the box, unless they are an OVF
+	// or OVA, which VMware Desktop can't run as is and are converted to a
+	// VMX instead
+	for _, path := range artifact.Files() {
+		switch filepath.Ext(path) {
+		case ".ova", ".ovf":
+			if hasVMX {
+				continue
+			}
+			ui.Message(fmt.Sprintf("Converting to VMX: %s", path))
+			var disks []BoxFile
+			if disks, err = convertToVMX(path, dir); err != nil {
+				return
+			}
+			files = append(files, disks...)
+			continue
+		case ".vmdk", ".mf", ".cert":
+			if !hasVMX {
+				// Disks of an OVF, which are converted along with it
+				continue
+			}
+		}
+		ui.Message(fmt.Sprintf("Adding: %s", path))
+		files = append(files, BoxFile{Name: filepath.Base(path), Path: path})
+	}
+
+	// The .vmx files are normalized into the temporary directory, for the
+	// box not to depend on the host it was built on
+	names := make(map[string]bool)
+	for _, f := range files {
+		names[f.Name] = true
+	}
+	converted, err := filepath.Glob(filepath.Join(dir, "*"))
+	if err != nil {
+		return
+	}
+	for _, path := range converted {
+		names[filepath.Base(path)] = true
+	}
+
+	var vmxFiles []string
+	var others []BoxFile
+	for _, f := range files {
+		if filepath.Ext(f.Name) != ".vmx" {
+			others = append(others, f)
+			continue
+		}
+		ui.Message(fmt.Sprintf("Normalizing: %s", f.Name))
+		if err = normalizeVMXFile(f.Path, filepath.Join(dir, f.Name), names); err != nil {
+			return
+		}
+		vmxFiles = append(vmxFiles, f.Name)
+	}
+	files = others
+	for _, path := range converted {
+		if filepath.Ext(path) != ".vmx" {
+			continue
+		}
+		ui.Message(fmt.Sprintf("Normalizing: %s", filepath.Base(path)))
+		if err = normalizeVMXFile(path, path, names); err != nil {
 			return
 		}
+		vmxFiles = append(vmxFiles, filepath.Base(path))
+	}
+
+	switch len(vmxFiles) {
+	case 0:
+	case 1:
+		metadata["vmx_file"] = vmxFiles[0]
+	default:
+		sort.Strings(vmxFiles)
+		err = fmt.Errorf("Found several .vmx files, can't tell which is the VM of the box: %s", strings.Join(vmxFiles, ", "))
 	}
 
 	return
 }
+
+// convertToVMX writes a VMX for the VM of an OVF or OVA into dir, along
+// with its disks. The disks of an OVF are stream-optimized VMDKs, which
+// VMware Desktop can't run, and are converted with vmware-vdiskmanager. The
+// other files the OVF references are returned, to be added to the box as
+// they are.
+func convertToVMX(src, dir string) ([]BoxFile, error) {
+	ovf := src
+	if filepath.Ext(src) == ".ova" {
+		extracted, err := tmp.Dir("packer-ova")
+		if err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(extracted)
+		if err := extractOva(src, extracted); err != nil {
+			return nil, err
+		}
+		matches, err := filepath.Glob(filepath.Join(extracted, "*.ovf"))
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) != 1 {
+			return nil, fmt.Errorf("%s should hold one OVF, found %d", filepath.Base(src), len(matches))
+		}
+		ovf = matches[0]
+	}
+
+	data, err := os.ReadFile(ovf)
+	if err != nil {
+		return nil, err
+	}
+	envelope, err := parseOvf(data)
+	if err != nil {
+		return nil, err
+	}
+
+	diskRefs := make(map[string]bool)
+	for _, disk := range envelope.Disks {
+		diskRefs[disk.FileRef] = true
+	}
+
+	// The manifest and certificate of the OVF aren't referenced, and are
+	// left out of the box since they would no longer match
+	var files []BoxFile
+	for _, ref := range envelope.Files {
+		href, err := archivePath(ref.Href)
+		if err != nil {
+			return nil, err
+		}
+		refPath := filepath.Join(filepath.Dir(ovf), filepath.FromSlash(href))
+		name := path.Base(href)
+		switch {
+		case diskRefs[ref.ID] && path.Ext(href) != ".vmdk":
+			return nil, fmt.Errorf("Disk %s of %s is not a VMDK", href, filepath.Base(src))
+		case diskRefs[ref.ID]:
+			if err := convertDisk(refPath, filepath.Join(dir, name)); err != nil {
+				return nil, fmt.Errorf("Error converting disk %s of %s: %s", href, filepath.Base(src), err)
+			}
+		case ovf != src:
+			// The OVA is extracted to a directory removed once converted
+			if err := os.Rename(refPath, filepath.Join(dir, name)); err != nil {
+				return nil, err
+			}
+		default:
+			files = append(files, BoxFile{Name: name, Path: refPath})
+		}
+	}
+
+	entries, err := vmxFromOvf(envelope)
+	if err != nil {
+		return nil, fmt.Errorf("Error converting %s to VMX: %s", filepath.Base(src), err)
+	}
+	name := strings.TrimSuffix(filepath.Base(src), filepath.Ext(src))
+	out, err := os.Create(filepath.Join(dir, name+".vmx"))
+	if err != nil {
+		return nil, err
+	}
+	defer out.Close()
+	if err := writeVMX(out, entries); err != nil {
+		return nil, err
+	}
+	return files, out.Close()
+}
+
+// extractOva extracts the files of an OVA into dir.
+func extractOva(src, dir string) error {
+	log.Printf("Turning ova to dir: %s => %s", src, dir)
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return extractTar(f, dir, defaultExtractLimits)
+}
+
+// convertDisk converts the stream-optimized VMDK at src to a growable one
+// at dst, which VMware Desktop can run. It is a variable for the tests to
+// replace it.
+var convertDisk = func(src, dst string) error {
+	vdiskmanager, err := vdiskmanagerPath()
+	if err != nil {
+		return err
+	}
+	log.Printf("Converting disk: %s => %s", src, dst)
+	out, err := exec.Command(vdiskmanager, "-r", src, "-t", "0", dst).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// vdiskmanagerPaths are where VMware Workstation and Fusion install
+// vmware-vdiskmanager, when it isn't in the PATH.
+var vdiskmanagerPaths = []string{
+	"/Applications/VMware Fusion.app/Contents/Library/vmware-vdiskmanager",
+	`C:\Program Files (x86)\VMware\VMware Workstation\vmware-vdiskmanager.exe`,
+	`C:\Program Files\VMware\VMware Workstation\vmware-vdiskmanager.exe`,
+}
+
+func vdiskmanagerPath() (string, error) {
+	if path, err := exec.LookPath("vmware-vdiskmanager"); err == nil {
+		return path, nil
+	}
+	for _, path := range vdiskmanagerPaths {
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", errors.New("vmware-vdiskmanager, which comes with VMware Workstation and Fusion, is required to convert the disks of an OVF or OVA")
+}
+
+// vmxFromOvf returns the settings of a VMX for the VM of an OVF: its
+// hardware, storage controllers, disks and network adapters. VMware fills in
+// the defaults of the other settings.
+func vmxFromOvf(envelope *ovfEnvelope) ([]vmxEntry, error) {
+	system := &envelope.VirtualSystem
+	hw := envelope.hardware()
+
+	version := "14"
+	for _, systemType := range strings.Fields(system.SystemType) {
+		if v, ok := strings.CutPrefix(systemType, "vmx-"); ok {
+			version = v
+			break
+		}
+	}
+	entries := []vmxEntry{
+		{".encoding", "UTF-8"},
+		{"config.version", "8"},
+		{"virtualHW.version", version},
+		{"displayName", system.ID},
+		{"guestOS", vmwareGuestOS(envelope)},
+		{"numvcpus", strconv.Itoa(hw.CPUs)},
+	}
+	if hw.Memory > 0 {
+		entries = append(entries, vmxEntry{"memsize", strconv.FormatInt(hw.Memory, 10)})
+	}
+	if hw.Firmware == "efi" {
+		entries = append(entries, vmxEntry{"firmware", "efi"})
+	}
+
+	// Storage controllers, numbered by bus
+	controllers := make(map[int]string)
+	buses := make(map[string]int)
+	for _, item := range system.Items {
+		var bus, virtualDev string
+		subType := strings.ToLower(item.ResourceSubType)
+		switch {
+		case item.ResourceType == ovfResourceIDE:
+			bus = "ide"
+		case item.ResourceType == ovfResourceSCSI:
+			bus = "scsi"
+			if virtualDev = vmxSCSIControllers[subType]; virtualDev == "" {
+				virtualDev = "lsilogic"
+			}
+		case item.ResourceType == ovfResourceOtherStorage && strings.Contains(subType, "ahci"):
+			bus = "sata"
+		case item.ResourceType == ovfResourceOtherStorage && strings.Contains(subType, "nvme"):
+			bus = "nvme"
+		case item.ResourceType == ovfResourceOtherStorage && subType == "lsilogicsas":
+			bus, virtualDev = "scsi", "lsisas1068"
+		case item.ResourceType == ovfResourceOtherStorage:
+			return nil, fmt.Errorf("Unsupported storage controller %s", item.ResourceSubType)
+		default:
+			continue
+		}
+		controller := fmt.Sprintf("%s%d", bus, buses[bus])
+		buses[bus]++
+		controllers[item.InstanceID] = controller
+		entries = append(entries, vmxEntry{controller + ".present", "TRUE"})
+		if virtualDev != "" {
+			entries = append(entries, vmxEntry{controller + ".virtualDev", virtualDev})
+		}
+	}
+
+	// Disks, found by ID in the disk section, then by file reference
+	hrefs := make(map[string]string)
+	for _, ref := range envelope.Files {
+		hrefs[ref.ID] = path.Base(ref.Href)
+	}
+	diskFiles := make(map[string]string)
+	for _, disk := range envelope.Disks {
+		diskFiles[disk.ID] = hrefs[disk.FileRef]
+	}
+	for _, item := range system.Items {
+		if item.ResourceType != ovfResourceDisk {
+			continue
+		}
+		id := item.HostResource[strings.LastIndex(item.HostResource, "/")+1:]
+		file := diskFiles[id]
+		if file == "" {
+			return nil, fmt.Errorf("Disk %s has no file", id)
+		}
+		controller, ok := controllers[item.Parent]
+		if !ok {
+			return nil, fmt.Errorf("Disk %s isn't attached to a storage controller", id)
+		}
+		device := fmt.Sprintf("%s:%d", controller, item.AddressOnParent)
+		entries = append(entries,
+			vmxEntry{device + ".present", "TRUE"},
+			vmxEntry{device + ".fileName", file},
+		)
+	}
+
+	for i, adapter := range hw.Adapters {
+		subType := adapter.Type
+		if ovfType, ok := ovfAdapters[subType]; ok {
+			subType = ovfType
+		}
+		virtualDev := vmxAdapters[strings.ToLower(subType)]
+		if virtualDev == "" {
+			virtualDev = "e1000"
+		}
+		ethernet := fmt.Sprintf("ethernet%d", i)
+		entries = append(entries,
+			vmxEntry{ethernet + ".present", "TRUE"},
+			vmxEntry{ethernet + ".virtualDev", virtualDev},
+			vmxEntry{ethernet + ".connectionType", "nat"},
+			vmxEntry{ethernet + ".addressType", "generated"},
+		)
+	}
+	return entries, nil
+}
+
+// vmxSCSIControllers maps the subtypes of the SCSI controllers of an OVF to
+// the virtualDev of VMware.
+var vmxSCSIControllers = map[string]string{
+	"lsilogic":    "lsilogic",
+	"lsilogicsas": "lsisas1068",
+	"buslogic":    "buslogic",
+	"virtualscsi": "pvscsi",
+}
+
+// vmxAdapters maps the subtypes of the network adapters of an OVF to the
+// virtualDev of VMware.
+var vmxAdapters = map[string]string{
+	"e1000":   "e1000",
+	"e1000e":  "e1000e",
+	"vmxnet3": "vmxnet3",
+	"pcnet32": "vlance",
+}
+
+// vmwareGuestOS returns the guestOS of the VMX for the VM of an OVF. VMware
+// exports the guest ID of vSphere, like ubuntu64Guest for ubuntu-64; other
+// OVFs only tell the architecture of the guest.
+func vmwareGuestOS(envelope *ovfEnvelope) string {
+	system := envelope.VirtualSystem.OperatingSystem
+	if id, ok := strings.CutSuffix(system.VMwareOSType, "Guest"); ok && id != "" {
+		id = strings.ToLower(id)
+		if base, ok := strings.CutSuffix(id, "64"); ok {
+			return strings.TrimSuffix(base, "_") + "-64"
+		}
+		return id
+	}
+
+	osType := system.OSType
+	if osType == "" {
+		osType = system.Description
+	}
+	if envelope.VirtualSystem.Machine != nil {
+		osType = envelope.VirtualSystem.Machine.OSType
+	}
+	switch virtualboxArchitecture(osType) {
+	case "amd64":
+		return "other-64"
+	case "arm64":
+		return "arm-other-64"
+	}
+	return "other"
+}
+
+// DetectArchitecture tells the architecture of the guest from the guestOS
+// set in the .vmx file of the artifact.
+func (p *VMwareProvider) DetectArchitecture(artifact packersdk.Artifact) (string, error) {
+	for _, path := range artifact.Files() {
+		if filepath.Ext(path) != ".vmx" {
+			continue
+		}
+		vmx, err := readVMX(path)
+		if err != nil {
+			return "", err
+		}
+		return vmwareArchitecture(vmx["guestos"]), nil
+	}
+	return "", nil
+}
+
+// vmwareArchitecture returns the architecture of a guestOS of VMware, like
+// ubuntu-64 or arm-ubuntu-64. Others, without suffix, are either 32-bit or
+// of an unknown architecture, like vmkernel7.
+func vmwareArchitecture(guestOS string) string {
+	guestOS = strings.ToLower(guestOS)
+	switch {
+	case strings.HasPrefix(guestOS, "arm-"):
+		return "arm64"
+	case strings.HasSuffix(guestOS, "-64"):
+		return "amd64"
+	}
+	return ""
+}
+
+// vmxEntry is a setting of a .vmx file.
+type vmxEntry struct {
+	Key   string
+	Value string
+}
+
+// parseVMX reads the settings of a .vmx file, in order.
+func parseVMX(r io.Reader) ([]vmxEntry, error) {
+	var entries []vmxEntry
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		entries = append(entries, vmxEntry{
+			Key:   strings.TrimSpace(key),
+			Value: strings.Trim(strings.TrimSpace(value), `"`),
+		})
+	}
+	return entries, scanner.Err()
+}
+
+// readVMX reads the settings of a .vmx file, with lowercase keys.
+func readVMX(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	entries, err := parseVMX(f)
+	if err != nil {
+		return nil, err
+	}
+	vmx := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		vmx[strings.ToLower(entry.Key)] = entry.Value
+	}
+	return vmx, nil
+}
+
+func writeVMX(w io.Writer, entries []vmxEntry) error {
+	for _, entry := range entries {
+		if _, err := fmt.Fprintf(w, "%s = \"%s\"\n", entry.Key, entry.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// vmxGenerated are the settings VMware generates for each VM, which would be
+// shared by all the VMs made from the box if kept.
+var vmxGenerated = []string{
+	"uuid.bios",
+	"uuid.location",
+	"vc.uuid",
+	"ethernet*.generatedaddress",
+	"ethernet*.generatedaddressoffset",
+}
+
+// normalizeVMX removes what ties the settings of a VM to the host it was
+// built on: the generated MAC addresses and UUIDs are dropped, CD-ROMs and
+// floppies using images of the build are detached, and absolute paths to
+// files of the box are made relative.
+func normalizeVMX(entries []vmxEntry, names map[string]bool) []vmxEntry {
+	deviceTypes := make(map[string]string)
+	for _, entry := range entries {
+		if device, ok := strings.CutSuffix(strings.ToLower(entry.Key), ".devicetype"); ok {
+			deviceTypes[device] = strings.ToLower(entry.Value)
+		}
+	}
+
+	var normalized []vmxEntry
+	for _, entry := range entries {
+		key := strings.ToLower(entry.Key)
+		device, setting := "", key
+		if i := strings.LastIndex(key, "."); i >= 0 {
+			device, setting = key[:i], key[i+1:]
+		}
+
+		generated := false
+		for _, pattern := range vmxGenerated {
+			if matched, _ := filepath.Match(pattern, key); matched {
+				generated = true
+			}
+		}
+		switch {
+		case generated:
+			continue
+		case deviceTypes[device] == "cdrom-image" && setting == "devicetype":
+			entry.Value = "cdrom-raw"
+		case deviceTypes[device] == "cdrom-image" && setting == "filename":
+			entry.Value = "auto detect"
+		case strings.HasPrefix(device, "floppy") && setting == "filename":
+			continue
+		case strings.HasPrefix(device, "floppy") && setting == "present":
+			entry.Value = "FALSE"
+		case setting == "filename" || key == "nvram" || key == "extendedconfigfile":
+			if name := vmxBaseName(entry.Value); name != entry.Value && names[name] {
+				entry.Value = name
+			}
+		}
+		normalized = append(normalized, entry)
+	}
+	return normalized
+}
+
+// vmxBaseName returns the name of the file at a path of a .vmx file, which
+// may come from Windows.
+func vmxBaseName(path string) string {
+	if i := strings.LastIndexAny(path, `/\`); i >= 0 {
+		return path[i+1:]
+	}
+	return path
+}
+
+// normalizeVMXFile writes the normalized settings of the .vmx file at src to
+// dst, which may be the same file.
+func normalizeVMXFile(src, dst string, names map[string]bool) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	entries, err := parseVMX(f)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("Error reading %s: %s", filepath.Base(src), err)
+	}
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	if err := writeVMX(out, normalizeVMX(entries, names)); err != nil {
+		return err
+	}
+	return out.Close()
+}
@@ -4,9 +4,312 @@
 package vagrant
 
 import (
+	"archive/tar"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 )
 
 func TestVMwareProvider_impl(t *testing.T) {
 	var _ Provider = new(VMwareProvider)
 }
+
+func TestVMwareProvider_DetectArchitecture(t *testing.T) {
+	cases := map[string]string{
+		"arm-ubuntu-64": "arm64",
+		"ubuntu-64":     "amd64",
+		"windows9":      "",
+		"vmkernel7":     "",
+		"other5xlinux":  "",
+	}
+	for guestOS, expected := range cases {
+		vmx := filepath.Join(t.TempDir(), "packer.vmx")
+		contents := fmt.Sprintf(".encoding = \"UTF-8\"\nguestOS = \"%s\"\nmemsize = \"2048\"\n", guestOS)
+		if err := os.WriteFile(vmx, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+		artifact := &packersdk.MockArtifact{FilesValue: []string{vmx}}
+		arch, err := new(VMwareProvider).DetectArchitecture(artifact)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if arch != expected {
+			t.Errorf("%s: expected %q, got %q", guestOS, expected, arch)
+		}
+	}
+}
+
+func TestVMwareProvider_Process(t *testing.T) {
+	artifactDir := t.TempDir()
+	vmx := filepath.Join(artifactDir, "packer.vmx")
+	disk := filepath.Join(artifactDir, "disk.vmdk")
+	contents := fmt.Sprintf(`.encoding = "UTF-8"
+displayName = "packer"
+guestOS = "ubuntu-64"
+uuid.bios = "56 4d 12 34 56 78 9a bc-de f0 12 34 56 78 9a bc"
+uuid.location = "56 4d 12 34 56 78 9a bc-de f0 12 34 56 78 9a bc"
+ethernet0.present = "TRUE"
+ethernet0.addressType = "generated"
+ethernet0.generatedAddress = "00:0c:29:78:9a:bc"
+ethernet0.generatedAddressOffset = "0"
+scsi0:0.fileName = "%s"
+ide1:0.deviceType = "cdrom-image"
+ide1:0.fileName = "/home/packer/iso/ubuntu.iso"
+floppy0.present = "TRUE"
+floppy0.fileName = "/tmp/packer-floppy.flp"
+nvram = "C:\Users\packer\output\packer.nvram"
+`, disk)
+	if err := os.WriteFile(vmx, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{disk, filepath.Join(artifactDir, "packer.nvram")} {
+		if err := os.WriteFile(path, []byte("data"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	artifact := &packersdk.MockArtifact{
+		FilesValue: []string{vmx, disk, filepath.Join(artifactDir, "packer.nvram")},
+	}
+	dir := t.TempDir()
+	_, metadata, files, err := new(VMwareProvider).Process(testUi(), artifact, dir)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if metadata["vmx_file"] != "packer.vmx" {
+		t.Fatalf("bad vmx_file: %#v", metadata["vmx_file"])
+	}
+	for _, f := range files {
+		if f.Name == "packer.vmx" {
+			t.Fatal("the original .vmx should be left out of the box")
+		}
+	}
+
+	normalized, err := readVMX(filepath.Join(dir, "packer.vmx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		".encoding":             "UTF-8",
+		"displayname":           "packer",
+		"guestos":               "ubuntu-64",
+		"ethernet0.present":     "TRUE",
+		"ethernet0.addresstype": "generated",
+		"scsi0:0.filename":      "disk.vmdk",
+		"ide1:0.devicetype":     "cdrom-raw",
+		"ide1:0.filename":       "auto detect",
+		"floppy0.present":       "FALSE",
+		"nvram":                 "packer.nvram",
+	}
+	if !reflect.DeepEqual(normalized, expected) {
+		t.Fatalf("bad normalized vmx:\n%#v", normalized)
+	}
+}
+
+// testVMwareOvf is an OVF as exported by VMware.
+var testVMwareOvf = `<?xml version="1.0" encoding="UTF-8"?>
+<Envelope xmlns="http://schemas.dmtf.org/ovf/envelope/1" xmlns:ovf="http://schemas.dmtf.org/ovf/envelope/1" xmlns:rasd="http://schemas.dmtf.org/wbem/wscim/1/cim-schema/2/CIM_ResourceAllocationSettingData" xmlns:vssd="http://schemas.dmtf.org/wbem/wscim/1/cim-schema/2/CIM_VirtualSystemSettingData" xmlns:vmw="http://www.vmware.com/schema/ovf">
+  <References>
+    <File ovf:href="packer-disk1.vmdk" ovf:id="file1" ovf:size="4"/>
+  </References>
+  <DiskSection>
+    <Disk ovf:capacity="20" ovf:capacityAllocationUnits="byte * 2^30" ovf:diskId="vmdisk1" ovf:fileRef="file1" ovf:format="http://www.vmware.com/interfaces/specifications/vmdk.html#streamOptimized"/>
+  </DiskSection>
+  <VirtualSystem ovf:id="packer">
+    <OperatingSystemSection ovf:id="94" vmw:osType="ubuntu64Guest">
+      <Description>Ubuntu Linux (64-bit)</Description>
+    </OperatingSystemSection>
+    <VirtualHardwareSection>
+      <System>
+        <vssd:VirtualSystemType>vmx-19</vssd:VirtualSystemType>
+      </System>
+      <Item>
+        <rasd:AllocationUnits>hertz * 10^6</rasd:AllocationUnits>
+        <rasd:InstanceID>1</rasd:InstanceID>
+        <rasd:ResourceType>3</rasd:ResourceType>
+        <rasd:VirtualQuantity>2</rasd:VirtualQuantity>
+      </Item>
+      <Item>
+        <rasd:AllocationUnits>byte * 2^20</rasd:AllocationUnits>
+        <rasd:InstanceID>2</rasd:InstanceID>
+        <rasd:ResourceType>4</rasd:ResourceType>
+        <rasd:VirtualQuantity>2048</rasd:VirtualQuantity>
+      </Item>
+      <Item>
+        <rasd:Address>0</rasd:Address>
+        <rasd:InstanceID>3</rasd:InstanceID>
+        <rasd:ResourceSubType>VirtualSCSI</rasd:ResourceSubType>
+        <rasd:ResourceType>6</rasd:ResourceType>
+      </Item>
+      <Item>
+        <rasd:AddressOnParent>0</rasd:AddressOnParent>
+        <rasd:HostResource>ovf:/disk/vmdisk1</rasd:HostResource>
+        <rasd:InstanceID>4</rasd:InstanceID>
+        <rasd:Parent>3</rasd:Parent>
+        <rasd:ResourceType>17</rasd:ResourceType>
+      </Item>
+      <Item>
+        <rasd:AddressOnParent>7</rasd:AddressOnParent>
+        <rasd:AutomaticAllocation>true</rasd:AutomaticAllocation>
+        <rasd:Connection>nat</rasd:Connection>
+        <rasd:InstanceID>5</rasd:InstanceID>
+        <rasd:ResourceSubType>VmxNet3</rasd:ResourceSubType>
+        <rasd:ResourceType>10</rasd:ResourceType>
+      </Item>
+      <vmw:Config ovf:required="false" vmw:key="firmware" vmw:value="efi"/>
+    </VirtualHardwareSection>
+  </VirtualSystem>
+</Envelope>
+`
+
+// testConvertDisk replaces the conversion of the disks of OVFs by a copy,
+// and returns the disks converted.
+func testConvertDisk(t *testing.T) *[]string {
+	var converted []string
+	convert := convertDisk
+	t.Cleanup(func() { convertDisk = convert })
+	convertDisk = func(src, dst string) error {
+		data, err := os.ReadFile(src)
+		if err != nil {
+			return err
+		}
+		converted = append(converted, filepath.Base(src))
+		return os.WriteFile(dst, append([]byte("converted "), data...), 0644)
+	}
+	return &converted
+}
+
+func TestVMwareProvider_Process_ova(t *testing.T) {
+	converted := testConvertDisk(t)
+	ova := filepath.Join(t.TempDir(), "packer.ova")
+	contents := testTar(t, []tarEntry{
+		{Name: "packer.ovf", Type: tar.TypeReg, Body: testVMwareOvf},
+		{Name: "packer.mf", Type: tar.TypeReg, Body: "SHA256(packer.ovf)= 00"},
+		{Name: "packer-disk1.vmdk", Type: tar.TypeReg, Body: "disk"},
+	})
+	if err := os.WriteFile(ova, contents.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	artifact := &packersdk.MockArtifact{FilesValue: []string{ova}}
+	dir := t.TempDir()
+	_, metadata, files, err := new(VMwareProvider).Process(testUi(), artifact, dir)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(files) != 0 {
+		t.Fatalf("nothing should be added from the OVA as is: %#v", files)
+	}
+	if !reflect.DeepEqual(*converted, []string{"packer-disk1.vmdk"}) {
+		t.Fatalf("the disk should have been converted: %#v", *converted)
+	}
+	disk, err := os.ReadFile(filepath.Join(dir, "packer-disk1.vmdk"))
+	if err != nil || string(disk) != "converted disk" {
+		t.Fatalf("the converted disk should go into the box: %q, %v", disk, err)
+	}
+	if metadata["vmx_file"] != "packer.vmx" {
+		t.Fatalf("bad vmx_file: %#v", metadata["vmx_file"])
+	}
+
+	vmx, err := readVMX(filepath.Join(dir, "packer.vmx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		".encoding":                "UTF-8",
+		"config.version":           "8",
+		"virtualhw.version":        "19",
+		"displayname":              "packer",
+		"guestos":                  "ubuntu-64",
+		"numvcpus":                 "2",
+		"memsize":                  "2048",
+		"firmware":                 "efi",
+		"scsi0.present":            "TRUE",
+		"scsi0.virtualdev":         "pvscsi",
+		"scsi0:0.present":          "TRUE",
+		"scsi0:0.filename":         "packer-disk1.vmdk",
+		"ethernet0.present":        "TRUE",
+		"ethernet0.virtualdev":     "vmxnet3",
+		"ethernet0.connectiontype": "nat",
+		"ethernet0.addresstype":    "generated",
+	}
+	if !reflect.DeepEqual(vmx, expected) {
+		t.Fatalf("bad vmx:\n%#v", vmx)
+	}
+}
+
+func TestVMwareProvider_Process_ovf(t *testing.T) {
+	converted := testConvertDisk(t)
+	artifactDir := t.TempDir()
+	ovf := filepath.Join(artifactDir, "packer.ovf")
+	disk := filepath.Join(artifactDir, "packer-disk1.vmdk")
+	if err := os.WriteFile(ovf, []byte(testVMwareOvf), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(disk, []byte("disk"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	artifact := &packersdk.MockArtifact{FilesValue: []string{ovf, disk}}
+	dir := t.TempDir()
+	_, _, files, err := new(VMwareProvider).Process(testUi(), artifact, dir)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(files) != 0 {
+		t.Fatalf("the original disk should be left out of the box: %#v", files)
+	}
+	if !reflect.DeepEqual(*converted, []string{"packer-disk1.vmdk"}) {
+		t.Fatalf("the disk should have been converted: %#v", *converted)
+	}
+	for _, name := range []string{"packer.vmx", "packer-disk1.vmdk"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestVMwareProvider_Process_severalVMX(t *testing.T) {
+	artifactDir := t.TempDir()
+	var paths []string
+	for _, name := range []string{"a.vmx", "b.vmx"} {
+		path := filepath.Join(artifactDir, name)
+		if err := os.WriteFile(path, []byte(".encoding = \"UTF-8\"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+
+	artifact := &packersdk.MockArtifact{FilesValue: paths}
+	_, _, _, err := new(VMwareProvider).Process(testUi(), artifact, t.TempDir())
+	if err == nil || !strings.Contains(err.Error(), "several .vmx files") {
+		t.Fatalf("expected an error about several .vmx files, got %v", err)
+	}
+}
+
+func TestVmwareGuestOS(t *testing.T) {
+	cases := []struct {
+		vmwareOSType, vboxOSType, expected string
+	}{
+		{"ubuntu64Guest", "", "ubuntu-64"},
+		{"windows9_64Guest", "", "windows9-64"},
+		{"windows2019srv_64Guest", "", "windows2019srv-64"},
+		{"otherLinuxGuest", "", "otherlinux"},
+		{"", "Ubuntu_64", "other-64"},
+		{"", "Ubuntu_arm64", "arm-other-64"},
+		{"", "", "other"},
+	}
+	for _, tc := range cases {
+		var envelope ovfEnvelope
+		envelope.VirtualSystem.OperatingSystem.VMwareOSType = tc.vmwareOSType
+		envelope.VirtualSystem.OperatingSystem.OSType = tc.vboxOSType
+		if guestOS := vmwareGuestOS(&envelope); guestOS != tc.expected {
+			t.Errorf("%s/%s: expected %q, got %q", tc.vmwareOSType, tc.vboxOSType, tc.expected, guestOS)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package vagrant
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"text/template"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+type vsphereVagrantfileTemplate struct {
+	Template   string
+	Datacenter string
+	Cluster    string
+	Host       string
+	Pool       string
+	BasePath   string
+}
+
+// VSphereProvider makes pointer boxes for vagrant-vsphere, which clone the
+// template the vsphere builders made.
+type VSphereProvider struct{}
+
+func (p *VSphereProvider) KeepInputArtifact() bool {
+	return true
+}
+
+func (p *VSphereProvider) Process(ui packersdk.Ui, artifact packersdk.Artifact, dir string) (vagrantfile string, metadata map[string]interface{}, files []BoxFile, err error) {
+	// Create the metadata
+	metadata = map[string]interface{}{"provider": "vsphere"}
+
+	// The ID of the artifact is the name of the template, which
+	// vagrant-vsphere finds by its path in the datacenter
+	name := artifact.Id()
+	if name == "" {
+		err = fmt.Errorf("No template name found in artifact: %s", artifact.String())
+		return
+	}
+	folder := artifactString(artifact, "folder", "Folder")
+
+	tplData := &vsphereVagrantfileTemplate{
+		Template:   path.Join(folder, name),
+		Datacenter: artifactString(artifact, "datacenter", "Datacenter"),
+		Cluster:    artifactString(artifact, "cluster", "Cluster"),
+		Host:       artifactString(artifact, "host", "Host"),
+		Pool:       artifactString(artifact, "resource_pool", "ResourcePool"),
+		BasePath:   folder,
+	}
+	metadata["template"] = tplData.Template
+	if tplData.Datacenter != "" {
+		metadata["datacenter"] = tplData.Datacenter
+	}
+
+	// Build up the Vagrantfile
+	var contents bytes.Buffer
+	t := template.Must(template.New("vf").Parse(defaultVSphereVagrantfile))
+	err = t.Execute(&contents, tplData)
+	vagrantfile = contents.String()
+	return
+}
+
+// artifactString returns a string of the state of the artifact, or of the
+// data generated by its builder when it is not in the state.
+func artifactString(artifact packersdk.Artifact, key, generatedKey string) string {
+	if value, ok := artifact.State(key).(string); ok && value != "" {
+		return value
+	}
+	return generatedString(artifact, generatedKey)
+}
+
+var defaultVSphereVagrantfile = `
+Vagrant.configure("2") do |config|
+  config.vm.provider :vsphere do |vsphere|
+    vsphere.template_name = "{{ .Template }}"
+    {{- if .Datacenter }}
+    vsphere.data_center_name = "{{ .Datacenter }}"
+    {{- end }}
+    {{- if .Cluster }}
+    vsphere.compute_resource_name = "{{ .Cluster }}"
+    {{- else if .Host }}
+    vsphere.compute_resource_name = "{{ .Host }}"
+    {{- end }}
+    {{- if .Pool }}
+    vsphere.resource_pool_name = "{{ .Pool }}"
+    {{- end }}
+    {{- if .BasePath }}
+    vsphere.vm_base_path = "{{ .BasePath }}"
+    {{- end }}
+  end
+end
+`
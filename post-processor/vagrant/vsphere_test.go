@@ -0,0 +1,60 @@
+// Copyright IBM Corp. 2013, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package vagrant
+
+import (
+	"strings"
+	"testing"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func TestVSphereProvider_impl(t *testing.T) {
+	var _ Provider = new(VSphereProvider)
+}
+
+func TestVSphereProvider_KeepInputArtifact(t *testing.T) {
+	p := new(VSphereProvider)
+
+	if !p.KeepInputArtifact() {
+		t.Fatal("should keep input artifact")
+	}
+}
+
+func TestVSphereProvider_ArtifactId(t *testing.T) {
+	p := new(VSphereProvider)
+	ui := testUi()
+	artifact := &packersdk.MockArtifact{
+		BuilderIdValue: "jetbrains.vsphere",
+		IdValue:        "ubuntu-22.04",
+		StateValues: map[string]interface{}{
+			"datacenter": "dc1",
+			"generated_data": map[interface{}]interface{}{
+				"Folder":  "templates/linux",
+				"Cluster": "cluster1",
+			},
+		},
+	}
+
+	vagrantfile, metadata, files, err := p.Process(ui, artifact, "foo")
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if len(files) != 0 {
+		t.Fatalf("pointer boxes have no files: %#v", files)
+	}
+	for _, result := range []string{
+		`vsphere.template_name = "templates/linux/ubuntu-22.04"`,
+		`vsphere.data_center_name = "dc1"`,
+		`vsphere.compute_resource_name = "cluster1"`,
+		`vsphere.vm_base_path = "templates/linux"`,
+	} {
+		if !strings.Contains(vagrantfile, result) {
+			t.Fatalf("missing %s: %s", result, vagrantfile)
+		}
+	}
+	if metadata["template"] != "templates/linux/ubuntu-22.04" || metadata["datacenter"] != "dc1" {
+		t.Fatalf("bad metadata: %#v", metadata)
+	}
+}